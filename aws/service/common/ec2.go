@@ -0,0 +1,41 @@
+package common
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Filters of an EC2 Describe call matching the fields passed as parameter, named after the filters of the call by
+// 'names'. Tags are filtered by 'tag:<key>' as well, and fields without a filter are left out. Filters are sorted by
+// field, so the calls are the same across runs
+func EC2Filters(names map[string]string, fields map[string]string) []types.Filter {
+	keys := make([]string, 0, len(fields))
+	for field := range fields {
+		keys = append(keys, field)
+	}
+	slices.Sort(keys)
+
+	var filters []types.Filter
+	for _, field := range keys {
+		name, ok := names[field]
+		if !ok && strings.HasPrefix(field, "tag:") {
+			name, ok = field, true
+		}
+		if ok {
+			filters = append(filters, types.Filter{Name: aws.String(name), Values: []string{fields[field]}})
+		}
+	}
+	return filters
+}
+
+// Tags of an EC2 resource as a map, along with its 'Name' tag
+func EC2TagMap(tags []types.Tag) (map[string]string, string) {
+	tagged := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		tagged[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tagged, tagged["Name"]
+}
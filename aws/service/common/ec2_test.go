@@ -0,0 +1,29 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEC2Filters(t *testing.T) {
+	names := map[string]string{"id": "volume-id", "state": "status"}
+
+	filters := EC2Filters(names, map[string]string{"state": "available", "tag:team": "data", "id": "vol-1", "size": "100"})
+
+	assert.Equal(t, []types.Filter{
+		{Name: aws.String("volume-id"), Values: []string{"vol-1"}},
+		{Name: aws.String("status"), Values: []string{"available"}},
+		{Name: aws.String("tag:team"), Values: []string{"data"}},
+	}, filters)
+	assert.Nil(t, EC2Filters(names, map[string]string{"size": "100"}))
+}
+
+func TestEC2TagMap(t *testing.T) {
+	tags, name := EC2TagMap([]types.Tag{{Key: aws.String("Name"), Value: aws.String("data")}, {Key: aws.String("team"), Value: aws.String("ml")}})
+
+	assert.Equal(t, map[string]string{"Name": "data", "team": "ml"}, tags)
+	assert.Equal(t, "data", name)
+}
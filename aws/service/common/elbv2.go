@@ -0,0 +1,42 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/loureirovinicius/cleanup/helpers/snapshot"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Maximum number of resources accepted by an ELBv2 DescribeTags call
+const elbv2TagsBatchSize = 20
+
+type ELBv2TagsAPI interface {
+	DescribeTags(ctx context.Context, params *elasticloadbalancingv2.DescribeTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTagsOutput, error)
+}
+
+// Fill in the tags of the ELBv2 resources (like LBs or TGs) passed as parameter, which aren't described along with them
+func DescribeELBv2Tags(ctx context.Context, api ELBv2TagsAPI, resources []resource.Resource) error {
+	tags := make(map[string]map[string]string, len(resources))
+	for _, batch := range snapshot.Batches(resource.IDs(resources), elbv2TagsBatchSize) {
+		out, err := api.DescribeTags(ctx, &elasticloadbalancingv2.DescribeTagsInput{ResourceArns: batch})
+		if err != nil {
+			return fmt.Errorf("error calling the AWS DescribeTags API: %w", err)
+		}
+
+		for _, description := range out.TagDescriptions {
+			tagged := make(map[string]string, len(description.Tags))
+			for _, tag := range description.Tags {
+				tagged[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+			tags[aws.ToString(description.ResourceArn)] = tagged
+		}
+	}
+
+	for i := range resources {
+		resources[i].Tags = tags[resources[i].ID]
+	}
+	return nil
+}
@@ -3,65 +3,151 @@ package elasticblockstorage
 import (
 	"context"
 	"fmt"
+	"strconv"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/loureirovinicius/cleanup/aws/service/common"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/helpers/snapshot"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 )
 
+// Maximum number of values accepted by a DescribeVolumes filter
+const refreshBatchSize = 200
+
+// Names of the DescribeVolumes filters matching fields of filter expressions. Tags are filtered by 'tag:<key>' as well
+var filterNames = map[string]string{
+	"id":                "volume-id",
+	"name":              "tag:Name",
+	"state":             "status",
+	"size":              "size",
+	"volume_type":       "volume-type",
+	"availability_zone": "availability-zone",
+}
+
 type ElasticBlockStorage struct {
 	API ElasticBlockStorageAPI
+
+	// Volumes found by the last List or Refresh call
+	volumes snapshot.Snapshot[types.Volume]
+
+	// Filters of the DescribeVolumes calls made by List
+	filters []types.Filter
 }
 
 type ElasticBlockStorageAPI interface {
-	DescribeVolumes(ctx context.Context, params *ec2.DescribeVolumesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesOutput, error)
+	ec2.DescribeVolumesAPIClient
 	DeleteVolume(ctx context.Context, params *ec2.DeleteVolumeInput, optFns ...func(*ec2.Options)) (*ec2.DeleteVolumeOutput, error)
+	CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error)
+	DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error)
 }
 
-func (r *ElasticBlockStorage) List(ctx context.Context) ([]string, error) {
-	var ebsIds []string
+func (r *ElasticBlockStorage) List(ctx context.Context) ([]resource.Resource, error) {
+	var volumes []resource.Resource
 
 	logger.Log(ctx, "debug", "Starting to list all the EBS volumes")
-	ebs, err := r.API.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{})
-	if err != nil {
-		return nil, fmt.Errorf("error calling the AWS DescribeVolumes API: %w", err)
-	}
+	r.volumes.Reset()
+	paginator := ec2.NewDescribeVolumesPaginator(r.API, &ec2.DescribeVolumesInput{Filters: r.filters})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error calling the AWS DescribeVolumes API: %w", err)
+		}
 
-	for _, ebs := range ebs.Volumes {
-		ebsIds = append(ebsIds, *ebs.VolumeId)
+		for _, ebs := range page.Volumes {
+			volumes = append(volumes, toResource(ebs))
+			r.volumes.Store(*ebs.VolumeId, ebs)
+		}
 	}
 
 	logger.Log(ctx, "debug", "Finished listing all the EBS volumes")
-	return ebsIds, nil
+	return volumes, nil
+}
+
+func (r *ElasticBlockStorage) Filter(fields map[string]string) {
+	r.filters = common.EC2Filters(filterNames, fields)
 }
 
-func (r *ElasticBlockStorage) Validate(ctx context.Context, id string) (bool, error) {
-	var tagged bool
+// Resource with the metadata of the volume passed as parameter
+func toResource(volume types.Volume) resource.Resource {
+	tags, name := common.EC2TagMap(volume.Tags)
+	return resource.Resource{
+		ID:        aws.ToString(volume.VolumeId),
+		Name:      name,
+		Type:      "AWS::EC2::Volume",
+		Tags:      tags,
+		CreatedAt: aws.ToTime(volume.CreateTime),
+		Attributes: map[string]string{
+			"state":             string(volume.State),
+			"size":              strconv.Itoa(int(aws.ToInt32(volume.Size))),
+			"volume_type":       string(volume.VolumeType),
+			"availability_zone": aws.ToString(volume.AvailabilityZone),
+		},
+	}
+}
+
+func (r *ElasticBlockStorage) Refresh(ctx context.Context, ids []string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Refreshing %d EBS volume(s)", len(ids)))
+	for _, batch := range snapshot.Batches(ids, refreshBatchSize) {
+		// Volumes that aren't returned anymore have been deleted in the meantime
+		for _, id := range batch {
+			r.volumes.StoreMissing(id)
+		}
+
+		// Unlike VolumeIds, a filter doesn't fail the whole call when one of the volumes is missing
+		input := &ec2.DescribeVolumesInput{Filters: []types.Filter{{Name: aws.String("volume-id"), Values: batch}}}
+		paginator := ec2.NewDescribeVolumesPaginator(r.API, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("error calling the AWS DescribeVolumes API: %w", err)
+			}
+
+			for _, ebs := range page.Volumes {
+				r.volumes.Store(*ebs.VolumeId, ebs)
+			}
+		}
+	}
+
+	logger.Log(ctx, "debug", "Finished refreshing the EBS volumes")
+	return nil
+}
 
+func (r *ElasticBlockStorage) Validate(ctx context.Context, id string) (resource.Verdict, error) {
 	logger.Log(ctx, "debug", fmt.Sprintf("Validating EBS volume: %v", id))
-	ebs, err := r.API.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{id}})
-	if err != nil {
-		return false, fmt.Errorf("error calling the AWS DescribeVolumes API: %w", err)
+	volume, known := r.volumes.Get(id)
+	if !known {
+		// The volume wasn't listed, so it has to be described
+		ebs, err := r.API.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{id}})
+		if err != nil {
+			return resource.Verdict{}, fmt.Errorf("error calling the AWS DescribeVolumes API: %w", err)
+		}
+		if len(ebs.Volumes) > 0 {
+			volume = &ebs.Volumes[0]
+		}
 	}
 
-	if len(ebs.Volumes) == 0 {
+	if volume == nil {
 		logger.Log(ctx, "info", "No volume found for ID: %v", id)
-		return false, nil
+		return resource.NewVerdict(resource.Reason{Rule: "found", Decision: resource.Unknown, Message: "volume was not found"}), nil
 	}
 
-	state := ebs.Volumes[0].State
+	state := volume.State
 	logger.Log(ctx, "debug", fmt.Sprintf("EBS state: %v", state))
-	tags := ebs.Volumes[0].Tags
-	logger.Log(ctx, "debug", fmt.Sprintf("EBS tags: %v", tags))
 
-	for _, v := range tags {
-		if *v.Key == "cleanup-ignore" && *v.Value == "true" {
-			tagged = true
-			break
-		}
+	stateReason := resource.Reason{Rule: "state", Decision: resource.Keep, Message: fmt.Sprintf("volume is %s", state), Evidence: map[string]string{"state": string(state)}}
+	if state == types.VolumeStateAvailable {
+		stateReason.Decision, stateReason.Message = resource.Deletable, "volume is available, so it's not attached to any instance"
 	}
 
 	logger.Log(ctx, "debug", "Finished validating the EBS volume")
-	return state == "available" && !tagged, nil
+	return resource.NewVerdict(stateReason), nil
+}
+
+func (r *ElasticBlockStorage) CheckedAttributes() []string {
+	return []string{"state"}
 }
 
 func (r *ElasticBlockStorage) Delete(ctx context.Context, id string) error {
@@ -74,3 +160,27 @@ func (r *ElasticBlockStorage) Delete(ctx context.Context, id string) error {
 	logger.Log(ctx, "debug", "Finished deleting the EBS volume")
 	return nil
 }
+
+func (r *ElasticBlockStorage) Tag(ctx context.Context, id, key, value string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Tagging EBS volume %v with: %s", id, key))
+	tag := types.Tag{Key: aws.String(key), Value: aws.String(value)}
+	_, err := r.API.CreateTags(ctx, &ec2.CreateTagsInput{Resources: []string{id}, Tags: []types.Tag{tag}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS CreateTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished tagging the EBS volume")
+	return nil
+}
+
+func (r *ElasticBlockStorage) Untag(ctx context.Context, id, key string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Untagging EBS volume %v from: %s", id, key))
+	tag := types.Tag{Key: aws.String(key)}
+	_, err := r.API.DeleteTags(ctx, &ec2.DeleteTagsInput{Resources: []string{id}, Tags: []types.Tag{tag}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS DeleteTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished untagging the EBS volume")
+	return nil
+}
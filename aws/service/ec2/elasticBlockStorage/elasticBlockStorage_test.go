@@ -4,12 +4,14 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	elasticblockstorage "github.com/loureirovinicius/cleanup/aws/service/ec2/elasticBlockStorage"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -33,6 +35,16 @@ func (m *MockEC2) DeleteVolume(ctx context.Context, input *ec2.DeleteVolumeInput
 	return args.Get(0).(*ec2.DeleteVolumeOutput), args.Error(1)
 }
 
+func (m *MockEC2) CreateTags(ctx context.Context, input *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*ec2.CreateTagsOutput), args.Error(1)
+}
+
+func (m *MockEC2) DeleteTags(ctx context.Context, input *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*ec2.DeleteTagsOutput), args.Error(1)
+}
+
 func TestList(t *testing.T) {
 	mockSvc := new(MockEC2)
 
@@ -61,8 +73,87 @@ func TestList(t *testing.T) {
 	// Assert the results
 	assert.NoError(t, err)
 	assert.Len(t, result, 2)
-	assert.Equal(t, "vol-1234567890abcdef0", result[0])
-	assert.Equal(t, "vol-1234567890abcdef1", result[1])
+	assert.Equal(t, "vol-1234567890abcdef0", result[0].ID)
+	assert.Equal(t, "vol-1234567890abcdef1", result[1].ID)
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
+
+func TestListMetadata(t *testing.T) {
+	mockSvc := new(MockEC2)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Mock AWS client response
+	mockSvc.On("DescribeVolumes", mock.Anything, mock.Anything).Return(&ec2.DescribeVolumesOutput{
+		Volumes: []types.Volume{
+			{
+				VolumeId:         aws.String("vol-1234567890abcdef0"),
+				State:            types.VolumeStateAvailable,
+				Size:             aws.Int32(100),
+				VolumeType:       types.VolumeTypeGp3,
+				AvailabilityZone: aws.String("us-east-1a"),
+				CreateTime:       &createdAt,
+				Tags:             []types.Tag{{Key: aws.String("Name"), Value: aws.String("data")}},
+			},
+		},
+	}, nil)
+
+	// Instantiate the object responsible for calling the methods
+	ebs := &elasticblockstorage.ElasticBlockStorage{
+		API: mockSvc,
+	}
+
+	// Call the "List" function
+	result, err := ebs.List(context.Background())
+
+	// Assert the volume was listed along with its metadata
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Resource{
+		{
+			ID:        "vol-1234567890abcdef0",
+			Name:      "data",
+			Type:      "AWS::EC2::Volume",
+			Tags:      map[string]string{"Name": "data"},
+			CreatedAt: createdAt,
+			Attributes: map[string]string{
+				"state":             "available",
+				"size":              "100",
+				"volume_type":       "gp3",
+				"availability_zone": "us-east-1a",
+			},
+		},
+	}, result)
+}
+
+func TestListMultiplePages(t *testing.T) {
+	mockSvc := new(MockEC2)
+
+	// Mock AWS client responses, the second page being requested with the token returned by the first one
+	mockSvc.On("DescribeVolumes", mock.Anything, &ec2.DescribeVolumesInput{}).Return(&ec2.DescribeVolumesOutput{
+		Volumes: []types.Volume{
+			{VolumeId: aws.String("vol-1234567890abcdef0")},
+			{VolumeId: aws.String("vol-1234567890abcdef1")},
+		},
+		NextToken: aws.String("page-2"),
+	}, nil).Once()
+	mockSvc.On("DescribeVolumes", mock.Anything, &ec2.DescribeVolumesInput{NextToken: aws.String("page-2")}).Return(&ec2.DescribeVolumesOutput{
+		Volumes: []types.Volume{
+			{VolumeId: aws.String("vol-1234567890abcdef2")},
+		},
+	}, nil).Once()
+
+	// Instantiate the object responsible for calling the methods
+	ebs := &elasticblockstorage.ElasticBlockStorage{
+		API: mockSvc,
+	}
+
+	// Call the "List" function
+	result, err := ebs.List(context.Background())
+
+	// Assert every page was listed
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vol-1234567890abcdef0", "vol-1234567890abcdef1", "vol-1234567890abcdef2"}, resource.IDs(result))
 
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
@@ -126,7 +217,7 @@ func TestValidate(t *testing.T) {
 			result, err := ebs.Validate(context.TODO(), *test.mockVolume.VolumeId)
 
 			assert.NoError(t, err)
-			assert.EqualValues(t, bool(test.expect), result)
+			assert.EqualValues(t, bool(test.expect), result.Deletable())
 
 			// Assert that the mock expectations were met
 			mockSvc.AssertExpectations(t)
@@ -136,6 +227,80 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidateReasons(t *testing.T) {
+	mockSvc := new(MockEC2)
+
+	// Mock AWS client response
+	mockSvc.On("DescribeVolumes", mock.Anything, mock.Anything).Return(&ec2.DescribeVolumesOutput{
+		Volumes: []types.Volume{
+			{
+				VolumeId: aws.String("vol-1234567890abcdef0"),
+				State:    types.VolumeStateAvailable,
+				Tags:     []types.Tag{{Key: aws.String("cleanup-ignore"), Value: aws.String("true")}},
+			},
+		},
+	}, nil)
+
+	// Instantiate the object responsible for calling the methods
+	ebs := &elasticblockstorage.ElasticBlockStorage{
+		API: mockSvc,
+	}
+
+	// Call the "Validate" function
+	verdict, err := ebs.Validate(context.Background(), "vol-1234567890abcdef0")
+
+	// Assert the volume is deletable because it's available. Protection tags are checked before the service is
+	// asked to validate it
+	assert.NoError(t, err)
+	assert.Equal(t, resource.Deletable, verdict.Decision)
+	assert.Equal(t, []resource.Reason{
+		{Rule: "state", Decision: resource.Deletable, Message: "volume is available, so it's not attached to any instance", Evidence: map[string]string{"state": "available"}},
+	}, verdict.Reasons)
+	assert.Equal(t, "volume is available, so it's not attached to any instance", verdict.String())
+}
+
+func TestValidateAfterListAndRefresh(t *testing.T) {
+	mockSvc := new(MockEC2)
+
+	// Mock AWS client responses: the first resource was released in the meantime and the second one is gone
+	mockSvc.On("DescribeVolumes", mock.Anything, &ec2.DescribeVolumesInput{}).Return(&ec2.DescribeVolumesOutput{
+		Volumes: []types.Volume{
+			{VolumeId: aws.String("vol-1234567890abcdef0"), State: types.VolumeStateInUse},
+			{VolumeId: aws.String("vol-1234567890abcdef1"), State: types.VolumeStateAvailable},
+		},
+	}, nil).Once()
+	mockSvc.On("DescribeVolumes", mock.Anything, &ec2.DescribeVolumesInput{Filters: []types.Filter{{Name: aws.String("volume-id"), Values: []string{"vol-1234567890abcdef0", "vol-1234567890abcdef1"}}}}).Return(&ec2.DescribeVolumesOutput{
+		Volumes: []types.Volume{
+			{VolumeId: aws.String("vol-1234567890abcdef0"), State: types.VolumeStateAvailable},
+		},
+	}, nil).Once()
+
+	// Instantiate the object responsible for calling the methods
+	ebs := &elasticblockstorage.ElasticBlockStorage{
+		API: mockSvc,
+	}
+
+	// Resources are validated against what was listed, without describing them again
+	_, err := ebs.List(context.Background())
+	assert.NoError(t, err)
+	verdict, err := ebs.Validate(context.Background(), "vol-1234567890abcdef0")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Deletable())
+	mockSvc.AssertNumberOfCalls(t, "DescribeVolumes", 1)
+
+	// And against their current state once they're refreshed
+	assert.NoError(t, ebs.Refresh(context.Background(), []string{"vol-1234567890abcdef0", "vol-1234567890abcdef1"}))
+	verdict, err = ebs.Validate(context.Background(), "vol-1234567890abcdef0")
+	assert.NoError(t, err)
+	assert.True(t, verdict.Deletable())
+	verdict, err = ebs.Validate(context.Background(), "vol-1234567890abcdef1")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Deletable())
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
+
 func TestDelete(t *testing.T) {
 	mockSvc := new(MockEC2)
 
@@ -158,3 +323,42 @@ func TestDelete(t *testing.T) {
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
 }
+
+func TestFilter(t *testing.T) {
+	mockSvc := new(MockEC2)
+	ebs := &elasticblockstorage.ElasticBlockStorage{API: mockSvc}
+
+	// Only the fields DescribeVolumes can filter by are pushed down to it, sorted by field
+	mockSvc.On("DescribeVolumes", mock.Anything, &ec2.DescribeVolumesInput{Filters: []types.Filter{
+		{Name: aws.String("status"), Values: []string{"available"}},
+		{Name: aws.String("tag:team"), Values: []string{"data"}},
+	}}).Return(&ec2.DescribeVolumesOutput{Volumes: []types.Volume{{VolumeId: aws.String("vol-1234567890abcdef0")}}}, nil)
+
+	ebs.Filter(map[string]string{"tag:team": "data", "state": "available", "encrypted": "true"})
+	result, err := ebs.List(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestTag(t *testing.T) {
+	mockSvc := new(MockEC2)
+	ebs := &elasticblockstorage.ElasticBlockStorage{API: mockSvc}
+
+	// Mock AWS client responses, tagging the resource and removing that tag
+	mockSvc.On("CreateTags", mock.Anything, &ec2.CreateTagsInput{
+		Resources: []string{"vol-1234567890abcdef0"},
+		Tags:      []types.Tag{{Key: aws.String(resource.MarkTag), Value: aws.String("2024-05-01T12:00:00Z")}},
+	}).Return(&ec2.CreateTagsOutput{}, nil)
+	mockSvc.On("DeleteTags", mock.Anything, &ec2.DeleteTagsInput{
+		Resources: []string{"vol-1234567890abcdef0"},
+		Tags:      []types.Tag{{Key: aws.String(resource.MarkTag)}},
+	}).Return(&ec2.DeleteTagsOutput{}, nil)
+
+	assert.NoError(t, ebs.Tag(context.Background(), "vol-1234567890abcdef0", resource.MarkTag, "2024-05-01T12:00:00Z"))
+	assert.NoError(t, ebs.Untag(context.Background(), "vol-1234567890abcdef0", resource.MarkTag))
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
@@ -4,54 +4,147 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/loureirovinicius/cleanup/aws/service/common"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/helpers/snapshot"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 )
 
+// Maximum number of values accepted by a DescribeAddresses filter
+const refreshBatchSize = 200
+
+// Names of the DescribeAddresses filters matching fields of filter expressions. Tags are filtered by 'tag:<key>' as well
+var filterNames = map[string]string{
+	"id":                   "allocation-id",
+	"name":                 "tag:Name",
+	"public_ip":            "public-ip",
+	"association_id":       "association-id",
+	"domain":               "domain",
+	"network_border_group": "network-border-group",
+}
+
 type ElasticIP struct {
 	API ElasticIPAPI
+
+	// EIPs found by the last List or Refresh call
+	eips snapshot.Snapshot[types.Address]
+
+	// Filters of the DescribeAddresses calls made by List
+	filters []types.Filter
 }
 
 type ElasticIPAPI interface {
 	DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error)
 	ReleaseAddress(ctx context.Context, params *ec2.ReleaseAddressInput, optFns ...func(*ec2.Options)) (*ec2.ReleaseAddressOutput, error)
+	CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error)
+	DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error)
 }
 
-func (r *ElasticIP) List(ctx context.Context) ([]string, error) {
-	var eipsIds []string
+func (r *ElasticIP) List(ctx context.Context) ([]resource.Resource, error) {
+	var addresses []resource.Resource
 
 	logger.Log(ctx, "debug", "Starting to list all the EIPs")
-	eips, err := r.API.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	r.eips.Reset()
+	// DescribeAddresses isn't paginated, every address is returned in a single response
+	eips, err := r.API.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{Filters: r.filters})
 	if err != nil {
 		return nil, fmt.Errorf("error calling the AWS DescribeAddresses API: %v", err)
 	}
 
 	for _, eip := range eips.Addresses {
-		eipsIds = append(eipsIds, *eip.AllocationId)
+		addresses = append(addresses, toResource(eip))
+		r.eips.Store(*eip.AllocationId, eip)
 	}
 
 	logger.Log(ctx, "debug", "Finished listing all the EIPs")
-	return eipsIds, nil
+	return addresses, nil
 }
 
-func (r *ElasticIP) Validate(ctx context.Context, id string) (bool, error) {
+func (r *ElasticIP) Filter(fields map[string]string) {
+	r.filters = common.EC2Filters(filterNames, fields)
+}
 
-	logger.Log(ctx, "debug", fmt.Sprintf("Starting the call to the DescribeAddresses API for EIP: %v", id))
-	eips, err := r.API.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{AllocationIds: []string{id}})
-	if err != nil {
-		return false, fmt.Errorf("error calling the AWS DescribeAddresses API: %v", err)
+// Resource with the metadata of the EIP passed as parameter
+func toResource(eip types.Address) resource.Resource {
+	tags, name := common.EC2TagMap(eip.Tags)
+	return resource.Resource{
+		ID:   aws.ToString(eip.AllocationId),
+		Name: name,
+		Type: "AWS::EC2::EIP",
+		Tags: tags,
+		Attributes: map[string]string{
+			"public_ip":            aws.ToString(eip.PublicIp),
+			"association_id":       aws.ToString(eip.AssociationId),
+			"domain":               string(eip.Domain),
+			"network_border_group": aws.ToString(eip.NetworkBorderGroup),
+		},
+	}
+}
+
+func (r *ElasticIP) Refresh(ctx context.Context, ids []string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Refreshing %d EIP(s)", len(ids)))
+	for _, batch := range snapshot.Batches(ids, refreshBatchSize) {
+		// EIPs that aren't returned anymore have been released in the meantime
+		for _, id := range batch {
+			r.eips.StoreMissing(id)
+		}
+
+		// Unlike AllocationIds, a filter doesn't fail the whole call when one of the EIPs is missing
+		eips, err := r.API.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{Filters: []types.Filter{{Name: aws.String("allocation-id"), Values: batch}}})
+		if err != nil {
+			return fmt.Errorf("error calling the AWS DescribeAddresses API: %v", err)
+		}
+
+		for _, eip := range eips.Addresses {
+			r.eips.Store(*eip.AllocationId, eip)
+		}
+	}
+
+	logger.Log(ctx, "debug", "Finished refreshing the EIPs")
+	return nil
+}
+
+func (r *ElasticIP) Validate(ctx context.Context, id string) (resource.Verdict, error) {
+	eip, known := r.eips.Get(id)
+	if !known {
+		// The EIP wasn't listed, so it has to be described
+		logger.Log(ctx, "debug", fmt.Sprintf("Starting the call to the DescribeAddresses API for EIP: %v", id))
+		eips, err := r.API.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{AllocationIds: []string{id}})
+		if err != nil {
+			return resource.Verdict{}, fmt.Errorf("error calling the AWS DescribeAddresses API: %v", err)
+		}
+		if len(eips.Addresses) > 0 {
+			eip = &eips.Addresses[0]
+		}
 	}
 
-	if len(eips.Addresses) == 0 {
+	if eip == nil {
 		logger.Log(ctx, "info", "No EIP found for ID: %v", id)
-		return false, nil
+		return resource.NewVerdict(resource.Reason{Rule: "found", Decision: resource.Unknown, Message: "EIP was not found"}), nil
 	}
 
-	status := eips.Addresses[0].AssociationId
+	status := eip.AssociationId
 	logger.Log(ctx, "debug", fmt.Sprintf("EIP address association ID: %v", status))
 
+	reason := resource.Reason{Rule: "association", Decision: resource.Deletable, Message: "EIP is not associated with any instance or network interface"}
+	if status != nil {
+		reason.Decision, reason.Message = resource.Keep, fmt.Sprintf("EIP is associated (%s)", *status)
+		reason.Evidence = map[string]string{
+			"association_id":       *status,
+			"instance_id":          aws.ToString(eip.InstanceId),
+			"network_interface_id": aws.ToString(eip.NetworkInterfaceId),
+		}
+	}
+
 	logger.Log(ctx, "debug", "Finished validating the EIP")
-	return status == nil, nil
+	return resource.NewVerdict(reason), nil
+}
+
+func (r *ElasticIP) CheckedAttributes() []string {
+	return []string{"association_id"}
 }
 
 func (r *ElasticIP) Delete(ctx context.Context, id string) error {
@@ -64,3 +157,27 @@ func (r *ElasticIP) Delete(ctx context.Context, id string) error {
 	logger.Log(ctx, "debug", "Finished releasing the EIP")
 	return nil
 }
+
+func (r *ElasticIP) Tag(ctx context.Context, id, key, value string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Tagging EIP %v with: %s", id, key))
+	tag := types.Tag{Key: aws.String(key), Value: aws.String(value)}
+	_, err := r.API.CreateTags(ctx, &ec2.CreateTagsInput{Resources: []string{id}, Tags: []types.Tag{tag}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS CreateTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished tagging the EIP")
+	return nil
+}
+
+func (r *ElasticIP) Untag(ctx context.Context, id, key string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Untagging EIP %v from: %s", id, key))
+	tag := types.Tag{Key: aws.String(key)}
+	_, err := r.API.DeleteTags(ctx, &ec2.DeleteTagsInput{Resources: []string{id}, Tags: []types.Tag{tag}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS DeleteTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished untagging the EIP")
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	elasticip "github.com/loureirovinicius/cleanup/aws/service/ec2/elasticIp"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -33,6 +34,16 @@ func (m *MockEC2) ReleaseAddress(ctx context.Context, params *ec2.ReleaseAddress
 	return args.Get(0).(*ec2.ReleaseAddressOutput), args.Error(1)
 }
 
+func (m *MockEC2) CreateTags(ctx context.Context, input *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*ec2.CreateTagsOutput), args.Error(1)
+}
+
+func (m *MockEC2) DeleteTags(ctx context.Context, input *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*ec2.DeleteTagsOutput), args.Error(1)
+}
+
 func TestList(t *testing.T) {
 	mockSvc := new(MockEC2)
 
@@ -61,8 +72,8 @@ func TestList(t *testing.T) {
 	// Assert the results
 	assert.NoError(t, err)
 	assert.Len(t, result, 2)
-	assert.Equal(t, "eipalloc-00a12b30", result[0])
-	assert.Equal(t, "eipalloc-00a12b31", result[1])
+	assert.Equal(t, "eipalloc-00a12b30", result[0].ID)
+	assert.Equal(t, "eipalloc-00a12b31", result[1].ID)
 
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
@@ -106,7 +117,7 @@ func TestValidate(t *testing.T) {
 			result, err := eip.Validate(context.TODO(), *test.mockEip.AllocationId)
 
 			assert.NoError(t, err)
-			assert.EqualValues(t, bool(test.expect), result)
+			assert.EqualValues(t, bool(test.expect), result.Deletable())
 
 			mockSvc.AssertExpectations(t)
 
@@ -116,6 +127,48 @@ func TestValidate(t *testing.T) {
 
 }
 
+func TestValidateAfterListAndRefresh(t *testing.T) {
+	mockSvc := new(MockEC2)
+
+	// Mock AWS client responses: the first resource was released in the meantime and the second one is gone
+	mockSvc.On("DescribeAddresses", mock.Anything, &ec2.DescribeAddressesInput{}).Return(&ec2.DescribeAddressesOutput{
+		Addresses: []types.Address{
+			{AllocationId: aws.String("eipalloc-12345678"), AssociationId: aws.String("eipassoc-12345678")},
+			{AllocationId: aws.String("eipalloc-87654321"), AssociationId: nil},
+		},
+	}, nil).Once()
+	mockSvc.On("DescribeAddresses", mock.Anything, &ec2.DescribeAddressesInput{Filters: []types.Filter{{Name: aws.String("allocation-id"), Values: []string{"eipalloc-12345678", "eipalloc-87654321"}}}}).Return(&ec2.DescribeAddressesOutput{
+		Addresses: []types.Address{
+			{AllocationId: aws.String("eipalloc-12345678"), AssociationId: nil},
+		},
+	}, nil).Once()
+
+	// Instantiate the object responsible for calling the methods
+	eip := &elasticip.ElasticIP{
+		API: mockSvc,
+	}
+
+	// Resources are validated against what was listed, without describing them again
+	_, err := eip.List(context.Background())
+	assert.NoError(t, err)
+	verdict, err := eip.Validate(context.Background(), "eipalloc-12345678")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Deletable())
+	mockSvc.AssertNumberOfCalls(t, "DescribeAddresses", 1)
+
+	// And against their current state once they're refreshed
+	assert.NoError(t, eip.Refresh(context.Background(), []string{"eipalloc-12345678", "eipalloc-87654321"}))
+	verdict, err = eip.Validate(context.Background(), "eipalloc-12345678")
+	assert.NoError(t, err)
+	assert.True(t, verdict.Deletable())
+	verdict, err = eip.Validate(context.Background(), "eipalloc-87654321")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Deletable())
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
+
 func TestDelete(t *testing.T) {
 	mockSvc := new(MockEC2)
 
@@ -138,3 +191,42 @@ func TestDelete(t *testing.T) {
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
 }
+
+func TestFilter(t *testing.T) {
+	mockSvc := new(MockEC2)
+	eip := &elasticip.ElasticIP{API: mockSvc}
+
+	// Only the fields DescribeAddresses can filter by are pushed down to it, sorted by field
+	mockSvc.On("DescribeAddresses", mock.Anything, &ec2.DescribeAddressesInput{Filters: []types.Filter{
+		{Name: aws.String("domain"), Values: []string{"vpc"}},
+		{Name: aws.String("tag:Name"), Values: []string{"reserved"}},
+	}}).Return(&ec2.DescribeAddressesOutput{Addresses: []types.Address{{AllocationId: aws.String("eipalloc-00a12b30")}}}, nil)
+
+	eip.Filter(map[string]string{"domain": "vpc", "name": "reserved", "region": "us-east-1"})
+	result, err := eip.List(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestTag(t *testing.T) {
+	mockSvc := new(MockEC2)
+	eip := &elasticip.ElasticIP{API: mockSvc}
+
+	// Mock AWS client responses, tagging the resource and removing that tag
+	mockSvc.On("CreateTags", mock.Anything, &ec2.CreateTagsInput{
+		Resources: []string{"eipalloc-12345678"},
+		Tags:      []types.Tag{{Key: aws.String(resource.MarkTag), Value: aws.String("2024-05-01T12:00:00Z")}},
+	}).Return(&ec2.CreateTagsOutput{}, nil)
+	mockSvc.On("DeleteTags", mock.Anything, &ec2.DeleteTagsInput{
+		Resources: []string{"eipalloc-12345678"},
+		Tags:      []types.Tag{{Key: aws.String(resource.MarkTag)}},
+	}).Return(&ec2.DeleteTagsOutput{}, nil)
+
+	assert.NoError(t, eip.Tag(context.Background(), "eipalloc-12345678", resource.MarkTag, "2024-05-01T12:00:00Z"))
+	assert.NoError(t, eip.Untag(context.Background(), "eipalloc-12345678", resource.MarkTag))
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
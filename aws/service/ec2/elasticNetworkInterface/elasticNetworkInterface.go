@@ -4,53 +4,152 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/loureirovinicius/cleanup/aws/service/common"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/helpers/snapshot"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 )
 
+// Maximum number of values accepted by a DescribeNetworkInterfaces filter
+const refreshBatchSize = 200
+
+// Names of the DescribeNetworkInterfaces filters matching fields of filter expressions. Tags are filtered by 'tag:<key>' as well
+var filterNames = map[string]string{
+	"id":                "network-interface-id",
+	"name":              "tag:Name",
+	"status":            "status",
+	"interface_type":    "interface-type",
+	"description":       "description",
+	"vpc_id":            "vpc-id",
+	"subnet_id":         "subnet-id",
+	"availability_zone": "availability-zone",
+}
+
 type ElasticNetworkInterface struct {
 	API ElasticNetworkInterfaceAPI
+
+	// ENIs found by the last List or Refresh call
+	enis snapshot.Snapshot[types.NetworkInterface]
+
+	// Filters of the DescribeNetworkInterfaces calls made by List
+	filters []types.Filter
 }
 
 type ElasticNetworkInterfaceAPI interface {
-	DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error)
+	ec2.DescribeNetworkInterfacesAPIClient
 	DeleteNetworkInterface(ctx context.Context, params *ec2.DeleteNetworkInterfaceInput, optFns ...func(*ec2.Options)) (*ec2.DeleteNetworkInterfaceOutput, error)
+	CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error)
+	DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error)
 }
 
-func (r *ElasticNetworkInterface) List(ctx context.Context) ([]string, error) {
-	var eniIds []string
+func (r *ElasticNetworkInterface) List(ctx context.Context) ([]resource.Resource, error) {
+	var enis []resource.Resource
 
 	logger.Log(ctx, "debug", "Starting to list all the ENIs")
-	enis, err := r.API.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{})
-	if err != nil {
-		return nil, fmt.Errorf("error calling the AWS DescribeNetworkInterfaces API: %v", err)
-	}
+	r.enis.Reset()
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(r.API, &ec2.DescribeNetworkInterfacesInput{Filters: r.filters})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error calling the AWS DescribeNetworkInterfaces API: %v", err)
+		}
 
-	for _, eni := range enis.NetworkInterfaces {
-		eniIds = append(eniIds, *eni.NetworkInterfaceId)
+		for _, eni := range page.NetworkInterfaces {
+			enis = append(enis, toResource(eni))
+			r.enis.Store(*eni.NetworkInterfaceId, eni)
+		}
 	}
 
 	logger.Log(ctx, "debug", "Finished listing all the ENIs")
-	return eniIds, nil
+	return enis, nil
+}
+
+func (r *ElasticNetworkInterface) Filter(fields map[string]string) {
+	r.filters = common.EC2Filters(filterNames, fields)
+}
+
+// Resource with the metadata of the ENI passed as parameter
+func toResource(eni types.NetworkInterface) resource.Resource {
+	tags, name := common.EC2TagMap(eni.TagSet)
+	return resource.Resource{
+		ID:   aws.ToString(eni.NetworkInterfaceId),
+		Name: name,
+		Type: "AWS::EC2::NetworkInterface",
+		Tags: tags,
+		Attributes: map[string]string{
+			"status":            string(eni.Status),
+			"interface_type":    string(eni.InterfaceType),
+			"description":       aws.ToString(eni.Description),
+			"vpc_id":            aws.ToString(eni.VpcId),
+			"subnet_id":         aws.ToString(eni.SubnetId),
+			"availability_zone": aws.ToString(eni.AvailabilityZone),
+		},
+	}
+}
+
+func (r *ElasticNetworkInterface) Refresh(ctx context.Context, ids []string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Refreshing %d ENI(s)", len(ids)))
+	for _, batch := range snapshot.Batches(ids, refreshBatchSize) {
+		// ENIs that aren't returned anymore have been deleted in the meantime
+		for _, id := range batch {
+			r.enis.StoreMissing(id)
+		}
+
+		// Unlike NetworkInterfaceIds, a filter doesn't fail the whole call when one of the ENIs is missing
+		input := &ec2.DescribeNetworkInterfacesInput{Filters: []types.Filter{{Name: aws.String("network-interface-id"), Values: batch}}}
+		paginator := ec2.NewDescribeNetworkInterfacesPaginator(r.API, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("error calling the AWS DescribeNetworkInterfaces API: %v", err)
+			}
+
+			for _, eni := range page.NetworkInterfaces {
+				r.enis.Store(*eni.NetworkInterfaceId, eni)
+			}
+		}
+	}
+
+	logger.Log(ctx, "debug", "Finished refreshing the ENIs")
+	return nil
 }
 
-func (r *ElasticNetworkInterface) Validate(ctx context.Context, id string) (bool, error) {
+func (r *ElasticNetworkInterface) Validate(ctx context.Context, id string) (resource.Verdict, error) {
 	logger.Log(ctx, "debug", fmt.Sprintf("Validating ENI: %v", id))
-	enis, err := r.API.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{id}})
-	if err != nil {
-		return false, fmt.Errorf("error calling the AWS DescribeNetworkInterfaces API: %v", err)
+	eni, known := r.enis.Get(id)
+	if !known {
+		// The ENI wasn't listed, so it has to be described
+		enis, err := r.API.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{id}})
+		if err != nil {
+			return resource.Verdict{}, fmt.Errorf("error calling the AWS DescribeNetworkInterfaces API: %v", err)
+		}
+		if len(enis.NetworkInterfaces) > 0 {
+			eni = &enis.NetworkInterfaces[0]
+		}
 	}
 
-	if len(enis.NetworkInterfaces) == 0 {
+	if eni == nil {
 		logger.Log(ctx, "info", "No ENI found for ID: %v", id)
-		return false, nil
+		return resource.NewVerdict(resource.Reason{Rule: "found", Decision: resource.Unknown, Message: "ENI was not found"}), nil
 	}
 
-	status := enis.NetworkInterfaces[0].Status
+	status := eni.Status
 	logger.Log(ctx, "debug", fmt.Sprintf("ENI status: %v", status))
 
+	reason := resource.Reason{Rule: "status", Decision: resource.Keep, Message: fmt.Sprintf("ENI is %s", status), Evidence: map[string]string{"status": string(status)}}
+	if status == types.NetworkInterfaceStatusAvailable {
+		reason.Decision, reason.Message = resource.Deletable, "ENI is available, so it's not attached to anything"
+	}
+
 	logger.Log(ctx, "debug", "Finished validating the ENI")
-	return status == "available", nil
+	return resource.NewVerdict(reason), nil
+}
+
+func (r *ElasticNetworkInterface) CheckedAttributes() []string {
+	return []string{"status"}
 }
 
 func (r *ElasticNetworkInterface) Delete(ctx context.Context, id string) error {
@@ -63,3 +162,27 @@ func (r *ElasticNetworkInterface) Delete(ctx context.Context, id string) error {
 	logger.Log(ctx, "debug", "Finished deleting the ENI")
 	return nil
 }
+
+func (r *ElasticNetworkInterface) Tag(ctx context.Context, id, key, value string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Tagging ENI %v with: %s", id, key))
+	tag := types.Tag{Key: aws.String(key), Value: aws.String(value)}
+	_, err := r.API.CreateTags(ctx, &ec2.CreateTagsInput{Resources: []string{id}, Tags: []types.Tag{tag}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS CreateTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished tagging the ENI")
+	return nil
+}
+
+func (r *ElasticNetworkInterface) Untag(ctx context.Context, id, key string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Untagging ENI %v from: %s", id, key))
+	tag := types.Tag{Key: aws.String(key)}
+	_, err := r.API.DeleteTags(ctx, &ec2.DeleteTagsInput{Resources: []string{id}, Tags: []types.Tag{tag}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS DeleteTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished untagging the ENI")
+	return nil
+}
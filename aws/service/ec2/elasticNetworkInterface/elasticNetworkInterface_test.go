@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	elasticnetworkinterface "github.com/loureirovinicius/cleanup/aws/service/ec2/elasticNetworkInterface"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -33,6 +34,16 @@ func (m *MockEC2) DeleteNetworkInterface(ctx context.Context, params *ec2.Delete
 	return args.Get(0).(*ec2.DeleteNetworkInterfaceOutput), args.Error(1)
 }
 
+func (m *MockEC2) CreateTags(ctx context.Context, input *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*ec2.CreateTagsOutput), args.Error(1)
+}
+
+func (m *MockEC2) DeleteTags(ctx context.Context, input *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*ec2.DeleteTagsOutput), args.Error(1)
+}
+
 func TestList(t *testing.T) {
 	mockSvc := new(MockEC2)
 
@@ -61,8 +72,41 @@ func TestList(t *testing.T) {
 	// Assert the results
 	assert.NoError(t, err)
 	assert.Len(t, result, 2)
-	assert.Equal(t, "eni-e1ab23a0", result[0])
-	assert.Equal(t, "eni-e1ab23a1", result[1])
+	assert.Equal(t, "eni-e1ab23a0", result[0].ID)
+	assert.Equal(t, "eni-e1ab23a1", result[1].ID)
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
+
+func TestListMultiplePages(t *testing.T) {
+	mockSvc := new(MockEC2)
+
+	// Mock AWS client responses, the second page being requested with the token returned by the first one
+	mockSvc.On("DescribeNetworkInterfaces", mock.Anything, &ec2.DescribeNetworkInterfacesInput{}).Return(&ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []types.NetworkInterface{
+			{NetworkInterfaceId: aws.String("eni-1234567890abcdef0")},
+			{NetworkInterfaceId: aws.String("eni-1234567890abcdef1")},
+		},
+		NextToken: aws.String("page-2"),
+	}, nil).Once()
+	mockSvc.On("DescribeNetworkInterfaces", mock.Anything, &ec2.DescribeNetworkInterfacesInput{NextToken: aws.String("page-2")}).Return(&ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []types.NetworkInterface{
+			{NetworkInterfaceId: aws.String("eni-1234567890abcdef2")},
+		},
+	}, nil).Once()
+
+	// Instantiate the object responsible for calling the methods
+	eni := &elasticnetworkinterface.ElasticNetworkInterface{
+		API: mockSvc,
+	}
+
+	// Call the "List" function
+	result, err := eni.List(context.Background())
+
+	// Assert every page was listed
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"eni-1234567890abcdef0", "eni-1234567890abcdef1", "eni-1234567890abcdef2"}, resource.IDs(result))
 
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
@@ -110,7 +154,7 @@ func TestValidate(t *testing.T) {
 			result, err := eni.Validate(context.TODO(), *test.mockEni.NetworkInterfaceId)
 
 			assert.NoError(t, err)
-			assert.EqualValues(t, bool(test.expect), result)
+			assert.EqualValues(t, bool(test.expect), result.Deletable())
 
 			mockSvc.AssertExpectations(t)
 
@@ -120,6 +164,48 @@ func TestValidate(t *testing.T) {
 
 }
 
+func TestValidateAfterListAndRefresh(t *testing.T) {
+	mockSvc := new(MockEC2)
+
+	// Mock AWS client responses: the first resource was released in the meantime and the second one is gone
+	mockSvc.On("DescribeNetworkInterfaces", mock.Anything, &ec2.DescribeNetworkInterfacesInput{}).Return(&ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []types.NetworkInterface{
+			{NetworkInterfaceId: aws.String("eni-1234567890abcdef0"), Status: types.NetworkInterfaceStatusInUse},
+			{NetworkInterfaceId: aws.String("eni-1234567890abcdef1"), Status: types.NetworkInterfaceStatusAvailable},
+		},
+	}, nil).Once()
+	mockSvc.On("DescribeNetworkInterfaces", mock.Anything, &ec2.DescribeNetworkInterfacesInput{Filters: []types.Filter{{Name: aws.String("network-interface-id"), Values: []string{"eni-1234567890abcdef0", "eni-1234567890abcdef1"}}}}).Return(&ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []types.NetworkInterface{
+			{NetworkInterfaceId: aws.String("eni-1234567890abcdef0"), Status: types.NetworkInterfaceStatusAvailable},
+		},
+	}, nil).Once()
+
+	// Instantiate the object responsible for calling the methods
+	eni := &elasticnetworkinterface.ElasticNetworkInterface{
+		API: mockSvc,
+	}
+
+	// Resources are validated against what was listed, without describing them again
+	_, err := eni.List(context.Background())
+	assert.NoError(t, err)
+	verdict, err := eni.Validate(context.Background(), "eni-1234567890abcdef0")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Deletable())
+	mockSvc.AssertNumberOfCalls(t, "DescribeNetworkInterfaces", 1)
+
+	// And against their current state once they're refreshed
+	assert.NoError(t, eni.Refresh(context.Background(), []string{"eni-1234567890abcdef0", "eni-1234567890abcdef1"}))
+	verdict, err = eni.Validate(context.Background(), "eni-1234567890abcdef0")
+	assert.NoError(t, err)
+	assert.True(t, verdict.Deletable())
+	verdict, err = eni.Validate(context.Background(), "eni-1234567890abcdef1")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Deletable())
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
+
 func TestDelete(t *testing.T) {
 	mockSvc := new(MockEC2)
 
@@ -142,3 +228,42 @@ func TestDelete(t *testing.T) {
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
 }
+
+func TestFilter(t *testing.T) {
+	mockSvc := new(MockEC2)
+	eni := &elasticnetworkinterface.ElasticNetworkInterface{API: mockSvc}
+
+	// Only the fields DescribeNetworkInterfaces can filter by are pushed down to it, sorted by field
+	mockSvc.On("DescribeNetworkInterfaces", mock.Anything, &ec2.DescribeNetworkInterfacesInput{Filters: []types.Filter{
+		{Name: aws.String("status"), Values: []string{"available"}},
+		{Name: aws.String("vpc-id"), Values: []string{"vpc-12345678"}},
+	}}).Return(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []types.NetworkInterface{{NetworkInterfaceId: aws.String("eni-12345678")}}}, nil)
+
+	eni.Filter(map[string]string{"vpc_id": "vpc-12345678", "status": "available", "type": "AWS::EC2::NetworkInterface"})
+	result, err := eni.List(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestTag(t *testing.T) {
+	mockSvc := new(MockEC2)
+	eni := &elasticnetworkinterface.ElasticNetworkInterface{API: mockSvc}
+
+	// Mock AWS client responses, tagging the resource and removing that tag
+	mockSvc.On("CreateTags", mock.Anything, &ec2.CreateTagsInput{
+		Resources: []string{"eni-12345678"},
+		Tags:      []types.Tag{{Key: aws.String(resource.MarkTag), Value: aws.String("2024-05-01T12:00:00Z")}},
+	}).Return(&ec2.CreateTagsOutput{}, nil)
+	mockSvc.On("DeleteTags", mock.Anything, &ec2.DeleteTagsInput{
+		Resources: []string{"eni-12345678"},
+		Tags:      []types.Tag{{Key: aws.String(resource.MarkTag)}},
+	}).Return(&ec2.DeleteTagsOutput{}, nil)
+
+	assert.NoError(t, eni.Tag(context.Background(), "eni-12345678", resource.MarkTag, "2024-05-01T12:00:00Z"))
+	assert.NoError(t, eni.Untag(context.Background(), "eni-12345678", resource.MarkTag))
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
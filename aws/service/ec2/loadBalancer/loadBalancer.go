@@ -3,9 +3,14 @@ package loadbalancer
 import (
 	"context"
 	"fmt"
+	"strconv"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/loureirovinicius/cleanup/aws/service/common"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 )
 
 type LoadBalancer struct {
@@ -13,39 +18,80 @@ type LoadBalancer struct {
 }
 
 type LoadBalancerAPI interface {
-	DescribeLoadBalancers(ctx context.Context, params *elasticloadbalancingv2.DescribeLoadBalancersInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeLoadBalancersOutput, error)
+	elasticloadbalancingv2.DescribeLoadBalancersAPIClient
 	DescribeListeners(ctx context.Context, params *elasticloadbalancingv2.DescribeListenersInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeListenersOutput, error)
 	DeleteLoadBalancer(ctx context.Context, params *elasticloadbalancingv2.DeleteLoadBalancerInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DeleteLoadBalancerOutput, error)
+	DescribeTags(ctx context.Context, params *elasticloadbalancingv2.DescribeTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTagsOutput, error)
+	AddTags(ctx context.Context, params *elasticloadbalancingv2.AddTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.AddTagsOutput, error)
+	RemoveTags(ctx context.Context, params *elasticloadbalancingv2.RemoveTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.RemoveTagsOutput, error)
 }
 
-func (r *LoadBalancer) List(ctx context.Context) ([]string, error) {
-	var lbArns []string
+func (r *LoadBalancer) List(ctx context.Context) ([]resource.Resource, error) {
+	var lbs []resource.Resource
 
 	logger.Log(ctx, "debug", "Starting to list all the LBs")
-	lbs, err := r.API.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{})
-	if err != nil {
-		return nil, fmt.Errorf("error calling the AWS DescribeLoadBalancers API: %w", err)
+	paginator := elasticloadbalancingv2.NewDescribeLoadBalancersPaginator(r.API, &elasticloadbalancingv2.DescribeLoadBalancersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error calling the AWS DescribeLoadBalancers API: %w", err)
+		}
+
+		for _, lb := range page.LoadBalancers {
+			lbs = append(lbs, toResource(lb))
+		}
 	}
 
-	for _, lb := range lbs.LoadBalancers {
-		lbArns = append(lbArns, *lb.LoadBalancerArn)
+	if err := common.DescribeELBv2Tags(ctx, r.API, lbs); err != nil {
+		return nil, err
 	}
 
 	logger.Log(ctx, "debug", "Finished listing all the LBs")
-	return lbArns, nil
+	return lbs, nil
 }
 
-func (r *LoadBalancer) Validate(ctx context.Context, arn string) (bool, error) {
+// Resource with the metadata of the LB passed as parameter
+func toResource(lb types.LoadBalancer) resource.Resource {
+	var state string
+	if lb.State != nil {
+		state = string(lb.State.Code)
+	}
+
+	return resource.Resource{
+		ID:        aws.ToString(lb.LoadBalancerArn),
+		ARN:       aws.ToString(lb.LoadBalancerArn),
+		Name:      aws.ToString(lb.LoadBalancerName),
+		Type:      "AWS::ElasticLoadBalancingV2::LoadBalancer",
+		CreatedAt: aws.ToTime(lb.CreatedTime),
+		Attributes: map[string]string{
+			// Named apart from the type of the resource, which filters and policies would match instead
+			"lb_type":  string(lb.Type),
+			"scheme":   string(lb.Scheme),
+			"state":    state,
+			"vpc_id":   aws.ToString(lb.VpcId),
+			"dns_name": aws.ToString(lb.DNSName),
+		},
+	}
+}
+
+// Listeners can only be described for one LB at a time, so they're always described here instead of while listing
+func (r *LoadBalancer) Validate(ctx context.Context, arn string) (resource.Verdict, error) {
 	logger.Log(ctx, "debug", fmt.Sprintf("Validating LB: %v", arn))
 	listeners, err := r.API.DescribeListeners(ctx, &elasticloadbalancingv2.DescribeListenersInput{LoadBalancerArn: &arn})
 	if err != nil {
-		return false, fmt.Errorf("error calling the AWS DescribeLoadBalancers API: %w", err)
+		return resource.Verdict{}, fmt.Errorf("error calling the AWS DescribeLoadBalancers API: %w", err)
 	}
 
-	logger.Log(ctx, "debug", fmt.Sprintf("LB listeners count: %v", len(listeners.Listeners)))
+	count := len(listeners.Listeners)
+	logger.Log(ctx, "debug", fmt.Sprintf("LB listeners count: %v", count))
+
+	reason := resource.Reason{Rule: "listeners", Decision: resource.Deletable, Message: "LB has no listeners", Evidence: map[string]string{"listeners": strconv.Itoa(count)}}
+	if count > 0 {
+		reason.Decision, reason.Message = resource.Keep, fmt.Sprintf("LB has %d listener(s)", count)
+	}
 
 	logger.Log(ctx, "debug", "Finished validating the LB")
-	return len(listeners.Listeners) == 0, nil
+	return resource.NewVerdict(reason), nil
 }
 
 func (r *LoadBalancer) Delete(ctx context.Context, arn string) error {
@@ -58,3 +104,26 @@ func (r *LoadBalancer) Delete(ctx context.Context, arn string) error {
 	logger.Log(ctx, "debug", "Finished deleting the LB")
 	return nil
 }
+
+func (r *LoadBalancer) Tag(ctx context.Context, arn, key, value string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Tagging LB %v with: %s", arn, key))
+	tag := types.Tag{Key: aws.String(key), Value: aws.String(value)}
+	_, err := r.API.AddTags(ctx, &elasticloadbalancingv2.AddTagsInput{ResourceArns: []string{arn}, Tags: []types.Tag{tag}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS AddTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished tagging the LB")
+	return nil
+}
+
+func (r *LoadBalancer) Untag(ctx context.Context, arn, key string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Untagging LB %v from: %s", arn, key))
+	_, err := r.API.RemoveTags(ctx, &elasticloadbalancingv2.RemoveTagsInput{ResourceArns: []string{arn}, TagKeys: []string{key}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS RemoveTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished untagging the LB")
+	return nil
+}
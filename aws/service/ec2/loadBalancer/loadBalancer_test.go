@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
 	loadbalancer "github.com/loureirovinicius/cleanup/aws/service/ec2/loadBalancer"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -38,6 +39,21 @@ func (m *MockEC2) DeleteLoadBalancer(ctx context.Context, params *elasticloadbal
 	return args.Get(0).(*elasticloadbalancingv2.DeleteLoadBalancerOutput), args.Error(1)
 }
 
+func (m *MockEC2) DescribeTags(ctx context.Context, params *elasticloadbalancingv2.DescribeTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTagsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*elasticloadbalancingv2.DescribeTagsOutput), args.Error(1)
+}
+
+func (m *MockEC2) AddTags(ctx context.Context, params *elasticloadbalancingv2.AddTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.AddTagsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*elasticloadbalancingv2.AddTagsOutput), args.Error(1)
+}
+
+func (m *MockEC2) RemoveTags(ctx context.Context, params *elasticloadbalancingv2.RemoveTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.RemoveTagsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*elasticloadbalancingv2.RemoveTagsOutput), args.Error(1)
+}
+
 func TestList(t *testing.T) {
 	mockSvc := new(MockEC2)
 
@@ -54,6 +70,11 @@ func TestList(t *testing.T) {
 
 	// Mock AWS client response
 	mockSvc.On("DescribeLoadBalancers", mock.Anything, mock.Anything).Return(mockOutput, nil)
+	mockSvc.On("DescribeTags", mock.Anything, mock.Anything).Return(&elasticloadbalancingv2.DescribeTagsOutput{
+		TagDescriptions: []types.TagDescription{
+			{ResourceArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900"), Tags: []types.Tag{{Key: aws.String("team"), Value: aws.String("finops")}}},
+		},
+	}, nil)
 
 	// Instantiate the object responsible for calling the methods
 	lb := &loadbalancer.LoadBalancer{
@@ -66,8 +87,44 @@ func TestList(t *testing.T) {
 	// Assert the results
 	assert.NoError(t, err)
 	assert.Len(t, result, 2)
-	assert.Equal(t, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900", result[0])
-	assert.Equal(t, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8901", result[1])
+	assert.Equal(t, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900", result[0].ID)
+	assert.Equal(t, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8901", result[1].ID)
+	assert.Equal(t, map[string]string{"team": "finops"}, result[0].Tags)
+	assert.Nil(t, result[1].Tags)
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
+
+func TestListMultiplePages(t *testing.T) {
+	mockSvc := new(MockEC2)
+
+	// Mock AWS client responses, the second page being requested with the token returned by the first one
+	mockSvc.On("DescribeLoadBalancers", mock.Anything, &elasticloadbalancingv2.DescribeLoadBalancersInput{}).Return(&elasticloadbalancingv2.DescribeLoadBalancersOutput{
+		LoadBalancers: []types.LoadBalancer{
+			{LoadBalancerArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900")},
+			{LoadBalancerArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8901")},
+		},
+		NextMarker: aws.String("page-2"),
+	}, nil).Once()
+	mockSvc.On("DescribeLoadBalancers", mock.Anything, &elasticloadbalancingv2.DescribeLoadBalancersInput{Marker: aws.String("page-2")}).Return(&elasticloadbalancingv2.DescribeLoadBalancersOutput{
+		LoadBalancers: []types.LoadBalancer{
+			{LoadBalancerArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8902")},
+		},
+	}, nil).Once()
+	mockSvc.On("DescribeTags", mock.Anything, mock.Anything).Return(&elasticloadbalancingv2.DescribeTagsOutput{}, nil)
+
+	// Instantiate the object responsible for calling the methods
+	lb := &loadbalancer.LoadBalancer{
+		API: mockSvc,
+	}
+
+	// Call the "List" function
+	result, err := lb.List(context.Background())
+
+	// Assert every page was listed
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900", "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8901", "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8902"}, resource.IDs(result))
 
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
@@ -115,7 +172,7 @@ func TestValidate(t *testing.T) {
 			result, err := lb.Validate(context.TODO(), "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900")
 
 			assert.NoError(t, err)
-			assert.EqualValues(t, bool(test.expect), result)
+			assert.EqualValues(t, bool(test.expect), result.Deletable())
 
 			// Assert that the mock expectations were met
 			mockSvc.AssertExpectations(t)
@@ -148,3 +205,24 @@ func TestDelete(t *testing.T) {
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
 }
+
+func TestTag(t *testing.T) {
+	mockSvc := new(MockEC2)
+	lb := &loadbalancer.LoadBalancer{API: mockSvc}
+
+	// Mock AWS client responses, tagging the resource and removing that tag
+	mockSvc.On("AddTags", mock.Anything, &elasticloadbalancingv2.AddTagsInput{
+		ResourceArns: []string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900"},
+		Tags:         []types.Tag{{Key: aws.String(resource.MarkTag), Value: aws.String("2024-05-01T12:00:00Z")}},
+	}).Return(&elasticloadbalancingv2.AddTagsOutput{}, nil)
+	mockSvc.On("RemoveTags", mock.Anything, &elasticloadbalancingv2.RemoveTagsInput{
+		ResourceArns: []string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900"},
+		TagKeys:      []string{resource.MarkTag},
+	}).Return(&elasticloadbalancingv2.RemoveTagsOutput{}, nil)
+
+	assert.NoError(t, lb.Tag(context.Background(), "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900", resource.MarkTag, "2024-05-01T12:00:00Z"))
+	assert.NoError(t, lb.Untag(context.Background(), "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900", resource.MarkTag))
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
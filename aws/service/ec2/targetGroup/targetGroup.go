@@ -3,49 +3,135 @@ package targetgroup
 import (
 	"context"
 	"fmt"
+	"strconv"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/loureirovinicius/cleanup/aws/service/common"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/helpers/snapshot"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 )
 
 type TargetGroup struct {
 	API TargetGroupAPI
+
+	// TGs found by the last List or Refresh call
+	tgs snapshot.Snapshot[types.TargetGroup]
 }
 
 type TargetGroupAPI interface {
-	DescribeTargetGroups(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetGroupsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTargetGroupsOutput, error)
+	elasticloadbalancingv2.DescribeTargetGroupsAPIClient
 	DeleteTargetGroup(ctx context.Context, params *elasticloadbalancingv2.DeleteTargetGroupInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DeleteTargetGroupOutput, error)
+	DescribeTags(ctx context.Context, params *elasticloadbalancingv2.DescribeTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTagsOutput, error)
+	AddTags(ctx context.Context, params *elasticloadbalancingv2.AddTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.AddTagsOutput, error)
+	RemoveTags(ctx context.Context, params *elasticloadbalancingv2.RemoveTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.RemoveTagsOutput, error)
 }
 
-func (r *TargetGroup) List(ctx context.Context) ([]string, error) {
-	var tgArns []string
-
+func (r *TargetGroup) List(ctx context.Context) ([]resource.Resource, error) {
 	logger.Log(ctx, "debug", "Starting to list all the TargetGroups")
-	tgs, err := r.API.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{})
+	r.tgs.Reset()
+	tgs, err := r.describeAll(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error calling the AWS DescribeTargetGroups API: %v", err)
+		return nil, err
 	}
-
-	for _, tg := range tgs.TargetGroups {
-		tgArns = append(tgArns, *tg.TargetGroupArn)
+	if err := common.DescribeELBv2Tags(ctx, r.API, tgs); err != nil {
+		return nil, err
 	}
 
 	logger.Log(ctx, "debug", "Finished listing all the TGs")
-	return tgArns, nil
+	return tgs, nil
+}
+
+func (r *TargetGroup) Refresh(ctx context.Context, arns []string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Refreshing %d TG(s)", len(arns)))
+
+	// TGs that aren't returned anymore have been deleted in the meantime
+	for _, arn := range arns {
+		r.tgs.StoreMissing(arn)
+	}
+
+	// A single missing TG fails a call filtering by ARNs, so every TG is described again instead, which only takes
+	// a few pages anyway
+	if _, err := r.describeAll(ctx); err != nil {
+		return err
+	}
+
+	logger.Log(ctx, "debug", "Finished refreshing the TGs")
+	return nil
+}
+
+// Describe every TG, storing them in the snapshot
+func (r *TargetGroup) describeAll(ctx context.Context) ([]resource.Resource, error) {
+	var tgs []resource.Resource
+
+	paginator := elasticloadbalancingv2.NewDescribeTargetGroupsPaginator(r.API, &elasticloadbalancingv2.DescribeTargetGroupsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error calling the AWS DescribeTargetGroups API: %v", err)
+		}
+
+		for _, tg := range page.TargetGroups {
+			tgs = append(tgs, toResource(tg))
+			r.tgs.Store(*tg.TargetGroupArn, tg)
+		}
+	}
+
+	return tgs, nil
+}
+
+// Resource with the metadata of the TG passed as parameter
+func toResource(tg types.TargetGroup) resource.Resource {
+	return resource.Resource{
+		ID:   aws.ToString(tg.TargetGroupArn),
+		ARN:  aws.ToString(tg.TargetGroupArn),
+		Name: aws.ToString(tg.TargetGroupName),
+		Type: "AWS::ElasticLoadBalancingV2::TargetGroup",
+		Attributes: map[string]string{
+			"protocol":       string(tg.Protocol),
+			"port":           strconv.Itoa(int(aws.ToInt32(tg.Port))),
+			"target_type":    string(tg.TargetType),
+			"vpc_id":         aws.ToString(tg.VpcId),
+			"load_balancers": strconv.Itoa(len(tg.LoadBalancerArns)),
+		},
+	}
 }
 
-func (r *TargetGroup) Validate(ctx context.Context, arn string) (bool, error) {
+func (r *TargetGroup) Validate(ctx context.Context, arn string) (resource.Verdict, error) {
 	logger.Log(ctx, "debug", fmt.Sprintf("Validating TG: %v", arn))
-	tgs, err := r.API.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{TargetGroupArns: []string{arn}})
-	if err != nil {
-		return false, fmt.Errorf("error calling the AWS DescribeTargetGroups API: %v", err)
+	tg, known := r.tgs.Get(arn)
+	if !known {
+		// The TG wasn't listed, so it has to be described
+		tgs, err := r.API.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{TargetGroupArns: []string{arn}})
+		if err != nil {
+			return resource.Verdict{}, fmt.Errorf("error calling the AWS DescribeTargetGroups API: %v", err)
+		}
+		if len(tgs.TargetGroups) > 0 {
+			tg = &tgs.TargetGroups[0]
+		}
+	}
+
+	if tg == nil {
+		logger.Log(ctx, "info", "No TG found for ARN: %v", arn)
+		return resource.NewVerdict(resource.Reason{Rule: "found", Decision: resource.Unknown, Message: "TG was not found"}), nil
 	}
 
-	lbs := len(tgs.TargetGroups[0].LoadBalancerArns)
+	lbs := len(tg.LoadBalancerArns)
 	logger.Log(ctx, "debug", fmt.Sprintf("LBs for TargetGroup (%v): %v", arn, lbs))
 
+	reason := resource.Reason{Rule: "load-balancers", Decision: resource.Deletable, Message: "TG is not used by any LB", Evidence: map[string]string{"load_balancers": strconv.Itoa(lbs)}}
+	if lbs > 0 {
+		reason.Decision, reason.Message = resource.Keep, fmt.Sprintf("TG is used by %d LB(s)", lbs)
+	}
+
 	logger.Log(ctx, "debug", "Finished validating the TG")
-	return lbs == 0, nil
+	return resource.NewVerdict(reason), nil
+}
+
+func (r *TargetGroup) CheckedAttributes() []string {
+	return []string{"load_balancers"}
 }
 
 func (r *TargetGroup) Delete(ctx context.Context, arn string) error {
@@ -58,3 +144,26 @@ func (r *TargetGroup) Delete(ctx context.Context, arn string) error {
 	logger.Log(ctx, "debug", "Finished deleting the TG")
 	return nil
 }
+
+func (r *TargetGroup) Tag(ctx context.Context, arn, key, value string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Tagging TG %v with: %s", arn, key))
+	tag := types.Tag{Key: aws.String(key), Value: aws.String(value)}
+	_, err := r.API.AddTags(ctx, &elasticloadbalancingv2.AddTagsInput{ResourceArns: []string{arn}, Tags: []types.Tag{tag}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS AddTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished tagging the TG")
+	return nil
+}
+
+func (r *TargetGroup) Untag(ctx context.Context, arn, key string) error {
+	logger.Log(ctx, "debug", fmt.Sprintf("Untagging TG %v from: %s", arn, key))
+	_, err := r.API.RemoveTags(ctx, &elasticloadbalancingv2.RemoveTagsInput{ResourceArns: []string{arn}, TagKeys: []string{key}})
+	if err != nil {
+		return fmt.Errorf("error calling the AWS RemoveTags API: %w", err)
+	}
+
+	logger.Log(ctx, "debug", "Finished untagging the TG")
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
 	targetgroup "github.com/loureirovinicius/cleanup/aws/service/ec2/targetGroup"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -33,6 +34,21 @@ func (m *MockEC2) DeleteTargetGroup(ctx context.Context, params *elasticloadbala
 	return args.Get(0).(*elasticloadbalancingv2.DeleteTargetGroupOutput), args.Error(1)
 }
 
+func (m *MockEC2) DescribeTags(ctx context.Context, params *elasticloadbalancingv2.DescribeTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.DescribeTagsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*elasticloadbalancingv2.DescribeTagsOutput), args.Error(1)
+}
+
+func (m *MockEC2) AddTags(ctx context.Context, params *elasticloadbalancingv2.AddTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.AddTagsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*elasticloadbalancingv2.AddTagsOutput), args.Error(1)
+}
+
+func (m *MockEC2) RemoveTags(ctx context.Context, params *elasticloadbalancingv2.RemoveTagsInput, optFns ...func(*elasticloadbalancingv2.Options)) (*elasticloadbalancingv2.RemoveTagsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*elasticloadbalancingv2.RemoveTagsOutput), args.Error(1)
+}
+
 func TestList(t *testing.T) {
 	mockSvc := new(MockEC2)
 
@@ -49,6 +65,11 @@ func TestList(t *testing.T) {
 
 	// Mock AWS client response
 	mockSvc.On("DescribeTargetGroups", mock.Anything, mock.Anything).Return(mockOutput, nil)
+	mockSvc.On("DescribeTags", mock.Anything, mock.Anything).Return(&elasticloadbalancingv2.DescribeTagsOutput{
+		TagDescriptions: []types.TagDescription{
+			{ResourceArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-load-balancer/12ab3c456d7e8900"), Tags: []types.Tag{{Key: aws.String("team"), Value: aws.String("finops")}}},
+		},
+	}, nil)
 
 	// Instantiate the object responsible for calling the methods
 	tg := &targetgroup.TargetGroup{
@@ -61,8 +82,44 @@ func TestList(t *testing.T) {
 	// Assert the results
 	assert.NoError(t, err)
 	assert.Len(t, result, 2)
-	assert.Equal(t, "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-load-balancer/12ab3c456d7e8900", result[0])
-	assert.Equal(t, "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-load-balancer/12ab3c456d7e8901", result[1])
+	assert.Equal(t, "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-load-balancer/12ab3c456d7e8900", result[0].ID)
+	assert.Equal(t, "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-load-balancer/12ab3c456d7e8901", result[1].ID)
+	assert.Equal(t, map[string]string{"team": "finops"}, result[0].Tags)
+	assert.Nil(t, result[1].Tags)
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
+
+func TestListMultiplePages(t *testing.T) {
+	mockSvc := new(MockEC2)
+
+	// Mock AWS client responses, the second page being requested with the token returned by the first one
+	mockSvc.On("DescribeTargetGroups", mock.Anything, &elasticloadbalancingv2.DescribeTargetGroupsInput{}).Return(&elasticloadbalancingv2.DescribeTargetGroupsOutput{
+		TargetGroups: []types.TargetGroup{
+			{TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8900")},
+			{TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8901")},
+		},
+		NextMarker: aws.String("page-2"),
+	}, nil).Once()
+	mockSvc.On("DescribeTargetGroups", mock.Anything, &elasticloadbalancingv2.DescribeTargetGroupsInput{Marker: aws.String("page-2")}).Return(&elasticloadbalancingv2.DescribeTargetGroupsOutput{
+		TargetGroups: []types.TargetGroup{
+			{TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8902")},
+		},
+	}, nil).Once()
+	mockSvc.On("DescribeTags", mock.Anything, mock.Anything).Return(&elasticloadbalancingv2.DescribeTagsOutput{}, nil)
+
+	// Instantiate the object responsible for calling the methods
+	tg := &targetgroup.TargetGroup{
+		API: mockSvc,
+	}
+
+	// Call the "List" function
+	result, err := tg.List(context.Background())
+
+	// Assert every page was listed
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8900", "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8901", "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8902"}, resource.IDs(result))
 
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
@@ -112,7 +169,7 @@ func TestValidate(t *testing.T) {
 			result, err := tg.Validate(context.TODO(), *test.mockTg.TargetGroupArn)
 
 			assert.NoError(t, err)
-			assert.EqualValues(t, bool(test.expect), result)
+			assert.EqualValues(t, bool(test.expect), result.Deletable())
 
 			// Assert that the mock expectations were met
 			mockSvc.AssertExpectations(t)
@@ -123,6 +180,50 @@ func TestValidate(t *testing.T) {
 
 }
 
+func TestValidateAfterListAndRefresh(t *testing.T) {
+	mockSvc := new(MockEC2)
+
+	// Mock AWS client responses: every TG is described again, the first one was released in the meantime and the
+	// second one is gone
+	mockSvc.On("DescribeTargetGroups", mock.Anything, &elasticloadbalancingv2.DescribeTargetGroupsInput{}).Return(&elasticloadbalancingv2.DescribeTargetGroupsOutput{
+		TargetGroups: []types.TargetGroup{
+			{TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8900"), LoadBalancerArns: []string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test-load-balancer/12ab3c456d7e8900"}},
+			{TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8901"), LoadBalancerArns: nil},
+		},
+	}, nil).Once()
+	mockSvc.On("DescribeTargetGroups", mock.Anything, &elasticloadbalancingv2.DescribeTargetGroupsInput{}).Return(&elasticloadbalancingv2.DescribeTargetGroupsOutput{
+		TargetGroups: []types.TargetGroup{
+			{TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8900"), LoadBalancerArns: nil},
+		},
+	}, nil).Once()
+	mockSvc.On("DescribeTags", mock.Anything, mock.Anything).Return(&elasticloadbalancingv2.DescribeTagsOutput{}, nil)
+
+	// Instantiate the object responsible for calling the methods
+	tg := &targetgroup.TargetGroup{
+		API: mockSvc,
+	}
+
+	// Resources are validated against what was listed, without describing them again
+	_, err := tg.List(context.Background())
+	assert.NoError(t, err)
+	verdict, err := tg.Validate(context.Background(), "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8900")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Deletable())
+	mockSvc.AssertNumberOfCalls(t, "DescribeTargetGroups", 1)
+
+	// And against their current state once they're refreshed
+	assert.NoError(t, tg.Refresh(context.Background(), []string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8900", "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8901"}))
+	verdict, err = tg.Validate(context.Background(), "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8900")
+	assert.NoError(t, err)
+	assert.True(t, verdict.Deletable())
+	verdict, err = tg.Validate(context.Background(), "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-target-group/12ab3c456d7e8901")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Deletable())
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
+
 func TestDelete(t *testing.T) {
 	mockSvc := new(MockEC2)
 
@@ -145,3 +246,24 @@ func TestDelete(t *testing.T) {
 	// Assert that the mock expectations were met
 	mockSvc.AssertExpectations(t)
 }
+
+func TestTag(t *testing.T) {
+	mockSvc := new(MockEC2)
+	tg := &targetgroup.TargetGroup{API: mockSvc}
+
+	// Mock AWS client responses, tagging the resource and removing that tag
+	mockSvc.On("AddTags", mock.Anything, &elasticloadbalancingv2.AddTagsInput{
+		ResourceArns: []string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-load-balancer/12ab3c456d7e8900"},
+		Tags:         []types.Tag{{Key: aws.String(resource.MarkTag), Value: aws.String("2024-05-01T12:00:00Z")}},
+	}).Return(&elasticloadbalancingv2.AddTagsOutput{}, nil)
+	mockSvc.On("RemoveTags", mock.Anything, &elasticloadbalancingv2.RemoveTagsInput{
+		ResourceArns: []string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-load-balancer/12ab3c456d7e8900"},
+		TagKeys:      []string{resource.MarkTag},
+	}).Return(&elasticloadbalancingv2.RemoveTagsOutput{}, nil)
+
+	assert.NoError(t, tg.Tag(context.Background(), "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-load-balancer/12ab3c456d7e8900", resource.MarkTag, "2024-05-01T12:00:00Z"))
+	assert.NoError(t, tg.Untag(context.Background(), "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/test-load-balancer/12ab3c456d7e8900", resource.MarkTag))
+
+	// Assert that the mock expectations were met
+	mockSvc.AssertExpectations(t)
+}
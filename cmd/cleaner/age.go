@@ -0,0 +1,127 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/helpers/duration"
+	"github.com/loureirovinicius/cleanup/helpers/state"
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/spf13/viper"
+)
+
+// Rule of the reasons telling whether resources are older than the minimum age
+const minAgeRule = "min-age"
+
+// Service whose unused resources are only deletable once they're older than the minimum age, so resources that are
+// still being provisioned aren't deleted. Resources that don't tell when they were created (like ENIs) are aged from
+// when they were first seen, as recorded in the state store
+type agedService struct {
+	providers.Cleanable
+	name   string
+	minAge time.Duration
+	now    time.Time
+	store  *state.Store
+	prefix string
+
+	// When each resource was created, or first seen when the service doesn't tell it, as found by the last List call
+	created map[string]time.Time
+	seen    map[string]bool
+}
+
+// Wrap the services passed as parameter with the minimum age set for them in the config file, if any
+func withMinAge(services []providers.Service, now time.Time, store *state.Store) error {
+	for i, service := range services {
+		key := fmt.Sprintf("services.%s.min_age", service.Name)
+		if !viper.IsSet(key) || viper.GetString(key) == "" {
+			continue
+		}
+
+		minAge, err := duration.Parse(viper.GetString(key))
+		if err != nil {
+			return fmt.Errorf("invalid minimum age for service '%s': %w", service.Name, err)
+		}
+		services[i].Cleanable = &agedService{
+			Cleanable: service.Cleanable,
+			name:      service.String(),
+			minAge:    minAge,
+			now:       now,
+			store:     store,
+			prefix:    statePrefix(service),
+		}
+	}
+	return nil
+}
+
+func (s *agedService) Unwrap() providers.Cleanable {
+	return s.Cleanable
+}
+
+func (s *agedService) rules() []string {
+	return []string{minAgeRule}
+}
+
+func (s *agedService) List(ctx context.Context) ([]resource.Resource, error) {
+	resources, err := s.Cleanable.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.created, s.seen = make(map[string]time.Time, len(resources)), map[string]bool{}
+	for _, res := range resources {
+		created := res.CreatedAt
+		if created.IsZero() {
+			// Without a state file, such resources would never be old enough to be deleted
+			if s.store == nil {
+				return nil, fmt.Errorf("minimum age of service '%s' requires a state file, since its resources don't tell when they were created", s.name)
+			}
+			created, s.seen[res.ID] = s.store.FirstSeen(s.prefix+res.ID, s.now), true
+		}
+		s.created[res.ID] = created
+	}
+	return resources, nil
+}
+
+// Resources that weren't listed (like the ones in a plan) already had their age checked when they were. Resources in
+// use only get a reason when they're too young, which keeps them even once their lease expired
+func (s *agedService) Validate(ctx context.Context, id string) (resource.Verdict, error) {
+	verdict, err := s.Cleanable.Validate(ctx, id)
+	if err != nil || verdict.Decision == resource.Unknown {
+		return verdict, err
+	}
+
+	created, listed := s.created[id]
+	if !listed {
+		return verdict, nil
+	}
+	reason := s.ageReason(created, s.seen[id])
+	if !verdict.Deletable() && reason.Decision != resource.Keep {
+		return verdict, nil
+	}
+	return resource.NewVerdict(append(slices.Clone(verdict.Reasons), reason)...), nil
+}
+
+// Reason telling whether a resource is older than the minimum age, aged from when it was first seen when its creation
+// time is unknown
+func (s *agedService) ageReason(created time.Time, seen bool) resource.Reason {
+	age := s.now.Sub(created)
+	event, key := "created", "created_at"
+	if seen {
+		event, key = "first seen", "first_seen_at"
+	}
+
+	reason := resource.Reason{
+		Rule:     minAgeRule,
+		Decision: resource.Deletable,
+		Message:  fmt.Sprintf("resource was %s %s ago, older than the minimum age of %s", event, duration.Format(age), duration.Format(s.minAge)),
+		Evidence: map[string]string{"min_age": duration.Format(s.minAge), key: created.Format(time.RFC3339), "age": duration.Format(age)},
+	}
+	if age < s.minAge {
+		reason.Decision = resource.Keep
+		reason.Message = fmt.Sprintf("resource was %s %s ago, less than the minimum age of %s", event, duration.Format(age), duration.Format(s.minAge))
+	}
+	return reason
+}
@@ -0,0 +1,66 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Delete the plan entries of the service passed as parameter, re-validating each one of them right before the deletion.
+// Nothing is deleted when the plan has more entries than the limits allow
+func apply(ctx context.Context, service providers.Cleanable, serviceName string, entries []planEntry, limits deletionLimits) (summary, error) {
+	logger.Log(ctx, "info", fmt.Sprintf("Applying plan for service: %s", serviceName))
+
+	// Plans may be stale or edited by hand, so they're held to the same limits as deletions. The resources are only
+	// listed when the ratio of deletions is limited
+	listed := 0
+	if limits.maxRatio > 0 {
+		resources, err := service.List(ctx)
+		if err != nil {
+			return summary{}, fmt.Errorf("error listing resources for service '%s': %w", serviceName, err)
+		}
+		listed = len(resources)
+	}
+	if err := limits.check(serviceName, len(entries), listed); err != nil {
+		return summary{}, err
+	}
+
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+
+	// Resources may have changed since the plan was created, so they must be validated again before being deleted
+	results := make([]deletion, len(ids))
+	_, err := refresh(ctx, service, serviceName, ids)
+	if err != nil {
+		return summary{}, err
+	}
+	err = forEach(ctx, concurrency, len(ids), func(ctx context.Context, i int) error {
+		return validateAndDelete(ctx, service, serviceName, ids[i], &results[i])
+	})
+
+	var sum summary
+	for i, id := range ids {
+		result := results[i]
+		sum.add(result.deleted, result.err)
+		sum.record(deletionResult(resource.Resource{ID: id}, result))
+
+		if result.validated && !result.verdict.Deletable() {
+			logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is not empty anymore and will be skipped.", id, serviceName), verdictAttrs(result.verdict)...)
+		}
+		if result.deleted {
+			logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' has been deleted successfully.", id, serviceName))
+		}
+	}
+
+	if err != nil {
+		return sum, err
+	}
+
+	logger.Log(ctx, "debug", fmt.Sprintf("Plan applied for service: %s", serviceName))
+	return sum, sum.err()
+}
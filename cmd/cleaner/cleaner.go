@@ -2,95 +2,464 @@ package cleaner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/loureirovinicius/cleanup/config"
+	"github.com/loureirovinicius/cleanup/helpers/duration"
+	"github.com/loureirovinicius/cleanup/helpers/filter"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/helpers/state"
 	"github.com/loureirovinicius/cleanup/providers"
 	"github.com/spf13/cobra"
 )
 
 var (
-	ctx      context.Context
-	debug    bool
-	output   string
-	provider string
-	rootCmd  = &cobra.Command{
-		Use:   "cleanup",
-		Short: "Cleanup - Cloud Provider Sanitization tool",
-		Long:  "Cleanup is a tool designed to accomplish effective costs on Cloud Providers (AWS, GCP, etc...) without wasting money on unused resources - an empty Load Balancer, for example. Such tool was thought to be one of the greatest allies in a FinOps culture for its simplicity, efficiency and security.",
+	ctx             context.Context
+	debug           bool
+	output          string
+	logFormat       string
+	provider        string
+	concurrency     int
+	keepGoing       bool
+	planFile        string
+	stdout          = io.Writer(os.Stdout)
+	stdin           = io.Reader(os.Stdin)
+	ids             []string
+	idsFrom         string
+	targets         *targetSet
+	resourceFilter  string
+	filterExpr      *filter.Expr
+	maxDeletions    int
+	maxDeleteRatio  float64
+	yes             bool
+	gracePeriod     string
+	stateFile       string
+	minIdleRuns     int
+	minIdleDuration string
+	extend          string
+	picker          *prompt
+	store           *state.Store
+	rootCmd         = &cobra.Command{
+		Use:           "cleanup",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		Short:         "Cleanup - Cloud Provider Sanitization tool",
+		Long:          "Cleanup is a tool designed to accomplish effective costs on Cloud Providers (AWS, GCP, etc...) without wasting money on unused resources - an empty Load Balancer, for example. Such tool was thought to be one of the greatest allies in a FinOps culture for its simplicity, efficiency and security.",
 	}
 
 	listCommand = &cobra.Command{
-		Use:   "list",
-		Short: "Lists all the created resources for a certain provider's service",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			// args[0] = service name (like ebs, eni, etc...)
-			serviceName := args[0]
-
-			// Load cloud provider resource that is being verified
-			service, err := providers.LoadProvider(ctx, provider, serviceName)
+		Use:   "list <service>... | all",
+		Short: "Lists all the created resources for a certain provider's services",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Only the resources matching the filter are listed, if any
+			var err error
+			filterExpr, err = loadFilter(resourceFilter)
 			if err != nil {
-				logger.Log(ctx, "error", err.Error())
-				return
+				return withExitCode(ExitFatal, err)
 			}
 
-			// List instances of a determined cloud provider resource
-			err = list(ctx, service, serviceName)
+			// args = service names (like ebs, eni, etc...) or 'all'
+			// Load cloud provider resources that are being verified
+			services, skipped, err := loadServices(ctx, cmd, provider, args...)
 			if err != nil {
-				logger.Log(ctx, "error", err.Error())
-				return
+				return err
+			}
+
+			// List instances of the determined cloud provider resources
+			rep, err := sweep(ctx, services, func(ctx context.Context, service providers.Service) (summary, error) {
+				return list(ctx, service, service.String())
+			})
+			if err := writeResults(cmd.Name(), rep); err != nil {
+				return err
+			}
+			if err := saveState(store); err != nil {
+				return withExitCode(ExitFatal, err)
 			}
+			return withExitCode(ExitFailure, errors.Join(skipped, err))
 		},
 	}
 
 	validateCommand = &cobra.Command{
-		Use:   "validate",
+		Use:   "validate <service>... | all",
 		Short: "Validates if resources can be deleted or not",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			// args[0] = service name (like ebs, eni, etc...)
-			serviceName := args[0]
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Only the resources passed through flags are validated, if any
+			var err error
+			targets, err = loadTargets(ids, idsFrom)
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			filterExpr, err = loadFilter(resourceFilter)
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
 
-			// Load cloud provider resource that is being verified
-			service, err := providers.LoadProvider(ctx, provider, serviceName)
+			// args = service names (like ebs, eni, etc...) or 'all'
+			// Load cloud provider resources that are being verified
+			services, skipped, err := loadServices(ctx, cmd, provider, args...)
 			if err != nil {
-				logger.Log(ctx, "error", err.Error())
-				return
+				return err
 			}
 
 			// Validate resources checking if they're unused
-			err = validate(ctx, service, serviceName)
-			if err != nil {
-				logger.Log(ctx, "error", err.Error())
-				return
+			now := time.Now()
+			rep, err := sweep(ctx, services, func(ctx context.Context, service providers.Service) (summary, error) {
+				return validate(ctx, withState(service, store, idlePolicy{}, now), service.String())
+			})
+			rep.log(ctx)
+			if err := writeResults(cmd.Name(), rep); err != nil {
+				return err
+			}
+			if err := saveState(store); err != nil {
+				return withExitCode(ExitFatal, err)
 			}
+			if err := errors.Join(skipped, err, targets.err(strings.Join(args, ", "))); err != nil {
+				return withExitCode(ExitFailure, err)
+			}
+
+			// Resources that can be deleted are reported through the exit code so pipelines can be gated on them
+			if found := rep.total().succeeded; found > 0 {
+				return withExitCode(ExitFindings, fmt.Errorf("%d resource(s) can be excluded", found))
+			}
+			return nil
 		},
 	}
 
 	deleteCommand = &cobra.Command{
-		Use:   "delete",
-		Short: "Deletes the unused resource",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			// args[0] = service name (like ebs, eni, etc...)
-			serviceName := args[0]
+		Use:   "delete <service>... | all",
+		Short: "Deletes the unused resources",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Only the resources passed through flags are deleted, if any
+			var err error
+			targets, err = loadTargets(ids, idsFrom)
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			filterExpr, err = loadFilter(resourceFilter)
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+
+			// Deletions must be confirmed, either interactively or beforehand through the '--yes' flag
+			picker, err = loadPicker()
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
 
-			// Load cloud provider resource that is being verified
-			service, err := providers.LoadProvider(ctx, provider, serviceName)
+			// args = service names (like ebs, eni, etc...) or 'all'
+			// Load cloud provider resources that are being verified
+			services, skipped, err := loadServices(ctx, cmd, provider, args...)
 			if err != nil {
-				logger.Log(ctx, "error", err.Error())
-				return
+				return err
 			}
 
 			// Delete unused resources found by the execution
-			err = delete(ctx, service, serviceName)
+			now := time.Now()
+			rep, err := sweep(ctx, services, func(ctx context.Context, service providers.Service) (summary, error) {
+				limits, err := limitsFor(cmd, service.Name)
+				if err != nil {
+					return summary{}, err
+				}
+				policy, err := idlePolicyFor(cmd, service.Name)
+				if err != nil {
+					return summary{}, err
+				}
+				if policy.enabled() && store == nil {
+					return summary{}, fmt.Errorf("minimum idle runs and duration of service '%s' require a state file to be set", service.Name)
+				}
+				return delete(ctx, withState(service, store, policy, now), service.String(), limits)
+			})
+			rep.log(ctx)
+			if err := writeResults(cmd.Name(), rep); err != nil {
+				return err
+			}
+			if err := saveState(store); err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			return withExitCode(ExitFailure, errors.Join(skipped, err, targets.err(strings.Join(args, ", "))))
+		},
+	}
+
+	markCommand = &cobra.Command{
+		Use:   "mark <service>... | all",
+		Short: "Tags the unused resources with the time they were found unused, so they can be swept later",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// args = service names (like ebs, eni, etc...) or 'all'
+			// Load cloud provider resources that are being verified
+			services, skipped, err := loadServices(ctx, cmd, provider, args...)
+			if err != nil {
+				return err
+			}
+
+			// Mark unused resources and unmark the ones in use again
+			now := time.Now()
+			rep, err := sweep(ctx, services, func(ctx context.Context, service providers.Service) (summary, error) {
+				return mark(ctx, service, service.String(), now)
+			})
+			rep.log(ctx)
+			if err := writeResults(cmd.Name(), rep); err != nil {
+				return err
+			}
+			if err := saveState(store); err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			return withExitCode(ExitFailure, errors.Join(skipped, err))
+		},
+	}
+
+	sweepCommand = &cobra.Command{
+		Use:   "sweep <service>... | all",
+		Short: "Deletes the unused resources that were marked longer than the grace period ago",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Only the resources passed through flags are swept, if any
+			var err error
+			targets, err = loadTargets(ids, idsFrom)
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			filterExpr, err = loadFilter(resourceFilter)
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+
+			// Deletions must be confirmed, either interactively or beforehand through the '--yes' flag
+			picker, err = loadPicker()
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+
+			// args = service names (like ebs, eni, etc...) or 'all'
+			// Load cloud provider resources that are being verified
+			services, skipped, err := loadServices(ctx, cmd, provider, args...)
+			if err != nil {
+				return err
+			}
+
+			// Delete unused resources whose grace period is over, and unmark the ones in use again
+			now := time.Now()
+			rep, err := sweep(ctx, services, func(ctx context.Context, service providers.Service) (summary, error) {
+				limits, err := limitsFor(cmd, service.Name)
+				if err != nil {
+					return summary{}, err
+				}
+				grace, err := gracePeriodFor(cmd, service.Name)
+				if err != nil {
+					return summary{}, err
+				}
+				marked, err := withGracePeriod(service, service.String(), grace, now)
+				if err != nil {
+					return summary{}, err
+				}
+				return delete(ctx, marked, service.String(), limits)
+			})
+			rep.log(ctx)
+			if err := writeResults(cmd.Name(), rep); err != nil {
+				return err
+			}
+			if err := saveState(store); err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			return withExitCode(ExitFailure, errors.Join(skipped, err, targets.err(strings.Join(args, ", "))))
+		},
+	}
+
+	planCommand = &cobra.Command{
+		Use:   "plan <service>... | all",
+		Short: "Writes a plan file with the unused resources that would be deleted",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// args = service names (like ebs, eni, etc...) or 'all'
+			// Load cloud provider resources that are being verified
+			services, skipped, err := loadServices(ctx, cmd, provider, args...)
+			if err != nil {
+				return err
+			}
+
+			p := &plan{
+				Version:   planVersion,
+				CreatedAt: time.Now().UTC(),
+				Provider:  provider,
+				Resources: []planEntry{},
+			}
+
+			// Validate resources and keep only the unused ones in the plan
+			rep, planErr := sweep(ctx, services, func(ctx context.Context, service providers.Service) (summary, error) {
+				entries, sum, err := planService(ctx, service, service.String())
+				for _, entry := range entries {
+					entry.Service, entry.Account, entry.Region = service.Name, service.Account, service.Region
+					p.Resources = append(p.Resources, entry)
+				}
+				if !slices.Contains(p.Services, service.Name) {
+					p.Services = append(p.Services, service.Name)
+				}
+				return sum, err
+			})
+			rep.log(ctx)
+			if err := writeResults(cmd.Name(), rep); err != nil {
+				return err
+			}
+			if err := saveState(store); err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+
+			// In keep-going mode the plan is still written when some resources couldn't be validated
+			if planErr != nil && !keepGoing {
+				return withExitCode(ExitFailure, planErr)
+			}
+
+			err = writePlan(planFile, p)
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			logger.Log(ctx, "info", fmt.Sprintf("Plan with %d resource(s) written to: %s", len(p.Resources), planFile))
+
+			return withExitCode(ExitFailure, errors.Join(skipped, planErr))
+		},
+	}
+
+	explainCommand = &cobra.Command{
+		Use:   "explain <service> <id>",
+		Short: "Explains why a resource can or cannot be deleted",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// args[0] = service name (like ebs, eni, etc...) or 'all', args[1] = resource ID or ARN
+			services, skipped, err := loadServices(ctx, cmd, provider, args[0])
+			if err != nil {
+				return err
+			}
+
+			// The resource is looked for in every account and region the service was loaded for
+			found := false
+			errs := []error{skipped}
+			for _, service := range services {
+				e, err := explain(logger.WithAttrs(ctx, service.LogAttrs()...), service, service.String(), args[1])
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				if e == nil {
+					continue
+				}
+
+				found = true
+				if err := e.write(stdout, output == "json" || output == "ndjson"); err != nil {
+					return withExitCode(ExitFatal, fmt.Errorf("error writing explanation: %w", err))
+				}
+			}
+
+			if err := saveState(store); err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			if err := errors.Join(errs...); err != nil {
+				return withExitCode(ExitFailure, err)
+			}
+			if !found {
+				return withExitCode(ExitFatal, fmt.Errorf("resource '%s' was not found in service '%s'", args[1], args[0]))
+			}
+			return nil
+		},
+	}
+
+	leaseCommand = &cobra.Command{
+		Use:   "lease <service> <id>",
+		Short: "Tags a resource with the time it expires at, keeping it until then and deleting it afterwards",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := duration.Parse(extend)
+			if err != nil {
+				return withExitCode(ExitFatal, fmt.Errorf("invalid lease extension: %w", err))
+			}
+			if d <= 0 {
+				return withExitCode(ExitFatal, fmt.Errorf("lease extension must be greater than zero, got: %s", extend))
+			}
+
+			// args[0] = service name (like ebs, eni, etc...) or 'all', args[1] = resource ID or ARN
+			services, skipped, err := loadServices(ctx, cmd, provider, args[0])
+			if err != nil {
+				return err
+			}
+
+			// The resource is looked for in every account and region the service was loaded for
+			found := false
+			errs := []error{skipped}
+			now := time.Now()
+			for _, service := range services {
+				_, ok, err := lease(logger.WithAttrs(ctx, service.LogAttrs()...), service, service.String(), args[1], d, now)
+				if err != nil {
+					errs = append(errs, err)
+				}
+				found = found || ok
+			}
+
+			if err := saveState(store); err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			if err := errors.Join(errs...); err != nil {
+				return withExitCode(ExitFailure, err)
+			}
+			if !found {
+				return withExitCode(ExitFatal, fmt.Errorf("resource '%s' was not found in service '%s'", args[1], args[0]))
+			}
+			return nil
+		},
+	}
+
+	applyCommand = &cobra.Command{
+		Use:   "apply <plan>",
+		Short: "Deletes the resources present in a plan file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// args[0] = plan file path
+			p, err := readPlan(args[0])
+			if err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+
+			// A plan must only be applied against the same provider, accounts and regions it was created for
+			if p.Provider != provider {
+				return withExitCode(ExitFatal, fmt.Errorf("plan was created for provider '%s', but provider '%s' is being used", p.Provider, provider))
+			}
+
+			// Load cloud provider resources that are being verified
+			services, skipped, err := loadServices(ctx, cmd, p.Provider, p.Services...)
 			if err != nil {
-				logger.Log(ctx, "error", err.Error())
-				return
+				return err
 			}
+
+			if err := p.checkTargets(services); err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+
+			// Delete the resources in the plan that are still unused
+			logger.Log(ctx, "info", fmt.Sprintf("Applying plan created at %s", p.CreatedAt.Format(time.RFC3339)))
+			rep, err := sweep(ctx, services, func(ctx context.Context, service providers.Service) (summary, error) {
+				limits, err := limitsFor(cmd, service.Name)
+				if err != nil {
+					return summary{}, err
+				}
+				return apply(ctx, service, service.String(), p.entries(service), limits)
+			})
+			rep.log(ctx)
+			if err := writeResults(cmd.Name(), rep); err != nil {
+				return err
+			}
+			if err := saveState(store); err != nil {
+				return withExitCode(ExitFatal, err)
+			}
+			return withExitCode(ExitFailure, errors.Join(skipped, err))
 		},
 	}
 )
@@ -98,16 +467,103 @@ var (
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&provider, "provider", "p", "aws", "Cloud Provider being used during execution")
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enables debug mode")
-	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "text", "Chooses between output format (text or JSON)")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "text", "Format of the results written to stdout (text, json, ndjson, csv or table). 'text' only logs them")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Format of the logs written to stderr (text or json)")
+	rootCmd.PersistentFlags().IntVarP(&concurrency, "concurrency", "c", 1, "Number of resources validated or deleted at the same time")
+	rootCmd.PersistentFlags().BoolVarP(&keepGoing, "keep-going", "k", false, "Keeps processing the remaining resources when one of them fails")
 	rootCmd.PersistentFlags().BoolP("help", "h", false, "Display help information")
-	rootCmd.AddCommand(listCommand, validateCommand, deleteCommand)
+	for _, cmd := range []*cobra.Command{validateCommand, deleteCommand, sweepCommand} {
+		cmd.Flags().StringArrayVar(&ids, "id", nil, "ID or ARN of a resource to act on, instead of every resource (repeatable)")
+		cmd.Flags().StringVar(&idsFrom, "ids-from", "", "File with newline-separated IDs or ARNs of the resources to act on ('-' reads from stdin)")
+	}
+	for _, cmd := range []*cobra.Command{listCommand, validateCommand, deleteCommand, sweepCommand} {
+		cmd.Flags().StringVar(&resourceFilter, "filter", "", `Expression the resources acted on must match, like 'tag:team == "data" && size > 100'`)
+	}
+	for _, cmd := range []*cobra.Command{deleteCommand, sweepCommand} {
+		cmd.Flags().IntVar(&maxDeletions, "max-deletions", 0, "Maximum number of resources deleted per service in each account and region, aborting the service there before any deletion when exceeded (0 means no limit)")
+		cmd.Flags().Float64Var(&maxDeleteRatio, "max-delete-ratio", 0, "Maximum ratio (0 to 1) of the listed resources deleted per service in each account and region, aborting the service there before any deletion when exceeded (0 means no limit)")
+		cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Deletes the unused resources without asking for confirmation. Required when not running on a terminal")
+	}
+	applyCommand.Flags().IntVar(&maxDeletions, "max-deletions", 0, "Maximum number of resources deleted per service in each account and region, aborting the service there before any deletion when the plan exceeds it (0 means no limit)")
+	applyCommand.Flags().Float64Var(&maxDeleteRatio, "max-delete-ratio", 0, "Maximum ratio (0 to 1) of the listed resources deleted per service in each account and region, aborting the service there before any deletion when the plan exceeds it (0 means no limit)")
+	for _, cmd := range []*cobra.Command{listCommand, validateCommand, deleteCommand, markCommand, sweepCommand, planCommand, explainCommand, leaseCommand, applyCommand} {
+		cmd.Flags().StringVar(&stateFile, "state-file", "", "File recording how long resources have been unused across runs, and when they were first seen")
+	}
+	deleteCommand.Flags().IntVar(&minIdleRuns, "min-idle-runs", 0, "Number of consecutive runs resources must have been seen unused in before being deleted. Requires a state file")
+	deleteCommand.Flags().StringVar(&minIdleDuration, "min-idle-duration", "", "How long resources must have been seen unused before being deleted (like 3d or 12h). Requires a state file")
+	leaseCommand.Flags().StringVar(&extend, "extend", "7d", "How long the lease is extended by, from when it expires or from now when it already expired (like 7d or 36h)")
+	sweepCommand.Flags().StringVar(&gracePeriod, "grace-period", "7d", "How long resources must have been marked before being swept (like 7d or 36h)")
+	planCommand.Flags().StringVarP(&planFile, "file", "f", "cleanup-plan.json", "Path of the plan file to be written")
+	rootCmd.AddCommand(listCommand, validateCommand, deleteCommand, markCommand, sweepCommand, planCommand, applyCommand, explainCommand, leaseCommand)
+}
+
+// Write the results of the command to stdout in the output format chosen
+func writeResults(command string, rep report) error {
+	if err := rep.write(stdout, output, command); err != nil {
+		return withExitCode(ExitFatal, fmt.Errorf("error writing results: %w", err))
+	}
+	return nil
+}
+
+// Load the services of the provider passed as parameter. Accounts that couldn't be accessed don't prevent the other
+// ones from being swept: their error is returned apart so it can be reported once the sweep is done
+func loadServices(ctx context.Context, cmd *cobra.Command, provider string, names ...string) ([]providers.Service, error, error) {
+	// The state file, if any, tells how long resources have been unused, and when the ones that don't tell when they
+	// were created were first seen
+	var err error
+	store, err = loadState(cmd)
+	if err != nil {
+		return nil, nil, withExitCode(ExitFatal, err)
+	}
+
+	var skipped error
+	services, err := providers.LoadProvider(ctx, provider, names...)
+	if errors.Is(err, providers.ErrAccountsSkipped) {
+		skipped, err = err, nil
+	}
+	if err != nil {
+		return nil, nil, withExitCode(ExitFatal, err)
+	}
+
+	// Rules of the policy file are evaluated along with the checks of their service, or instead of them
+	now := time.Now()
+	if err := withPolicy(services, now); err != nil {
+		return nil, nil, withExitCode(ExitFatal, err)
+	}
+	// Resources younger than the minimum age of their service are never deleted, whatever the command
+	if err := withMinAge(services, now, store); err != nil {
+		return nil, nil, withExitCode(ExitFatal, err)
+	}
+	// Resources with protection tags are kept whatever their service says, and so are leased resources until they expire
+	if err := withProtection(services, now); err != nil {
+		return nil, nil, withExitCode(ExitFatal, err)
+	}
+
+	return services, skipped, nil
 }
 
-// Start the cleaner
+// Start the cleaner. Errors are logged before being returned, and ExitCode tells which exit code they map to
 func Run() error {
-	ctx = context.Background()
+	// Stop handing out work as soon as the execution is interrupted
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Explicitly parse flags early
+	// Fallback logger for errors happening before flags are parsed
+	logger.InitializeLogger("info", "text", os.Stderr)
+
+	err := run()
+	// Findings aren't failures, they were already reported by the validation itself
+	if err != nil && ExitCode(err) != ExitFindings {
+		logger.Log(ctx, "error", err.Error())
+	}
+
+	return err
+}
+
+func run() error {
+	// Explicitly parse flags early. Command specific flags are only known by cobra, so they're ignored here
+	rootCmd.PersistentFlags().ParseErrorsWhitelist.UnknownFlags = true
 	err := rootCmd.PersistentFlags().Parse(os.Args[1:])
 	if err != nil {
 		return fmt.Errorf("failed to parse flags: %w", err)
@@ -125,11 +581,35 @@ func Run() error {
 		return fmt.Errorf("could not get 'debug' flag: %w", err)
 	}
 
-	// Access the parsed flags and set the log format based on their values
+	// Access the parsed flags and set the results format based on their values
 	output, err = rootCmd.PersistentFlags().GetString("output")
 	if err != nil {
 		return fmt.Errorf("could not get 'output' flag: %w", err)
 	}
+	if err := checkOutputFormat(output); err != nil {
+		return err
+	}
+
+	// Access the parsed flags and set the log format based on their values
+	logFormat, err = rootCmd.PersistentFlags().GetString("log-format")
+	if err != nil {
+		return fmt.Errorf("could not get 'log-format' flag: %w", err)
+	}
+
+	// Access the parsed flags and validate the number of workers
+	concurrency, err = rootCmd.PersistentFlags().GetInt("concurrency")
+	if err != nil {
+		return fmt.Errorf("could not get 'concurrency' flag: %w", err)
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("'concurrency' flag must be greater than zero, got: %d", concurrency)
+	}
+
+	// Access the parsed flags and check whether failures should interrupt the execution
+	keepGoing, err = rootCmd.PersistentFlags().GetBool("keep-going")
+	if err != nil {
+		return fmt.Errorf("could not get 'keep-going' flag: %w", err)
+	}
 
 	level := "info"
 	// Enable debug logs
@@ -137,7 +617,8 @@ func Run() error {
 		level = "debug"
 	}
 
-	logger.InitializeLogger(level, output, os.Stdout)
+	// Logs are kept apart from the results, so the results can be piped into other tools
+	logger.InitializeLogger(level, logFormat, os.Stderr)
 
 	// Start initialization of configuration
 	logger.Log(ctx, "debug", "Initializing configs...")
@@ -1,15 +1,25 @@
 package cleaner
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/helpers/state"
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -25,14 +35,25 @@ type MockCleanable struct {
 	mock.Mock
 }
 
-func (m *MockCleanable) List(ctx context.Context) ([]string, error) {
+// Resources are mocked either with their metadata or only with their IDs
+func (m *MockCleanable) List(ctx context.Context) ([]resource.Resource, error) {
 	args := m.Called(ctx)
-	return args.Get(0).([]string), args.Error(1)
+	if ids, ok := args.Get(0).([]string); ok {
+		return resource.FromIDs(ids), args.Error(1)
+	}
+	return args.Get(0).([]resource.Resource), args.Error(1)
 }
 
-func (m *MockCleanable) Validate(ctx context.Context, resource string) (bool, error) {
-	args := m.Called(ctx, resource)
-	return args.Bool(0), args.Error(1)
+// Verdicts are mocked either with their reasons or only by whether the resource is empty
+func (m *MockCleanable) Validate(ctx context.Context, id string) (resource.Verdict, error) {
+	args := m.Called(ctx, id)
+	if empty, ok := args.Get(0).(bool); ok {
+		if empty {
+			return resource.NewVerdict(resource.Reason{Rule: "empty", Decision: resource.Deletable, Message: "resource is empty"}), args.Error(1)
+		}
+		return resource.NewVerdict(resource.Reason{Rule: "empty", Decision: resource.Keep, Message: "resource is not empty"}), args.Error(1)
+	}
+	return args.Get(0).(resource.Verdict), args.Error(1)
 }
 
 func (m *MockCleanable) Delete(ctx context.Context, resource string) error {
@@ -40,6 +61,49 @@ func (m *MockCleanable) Delete(ctx context.Context, resource string) error {
 	return args.Error(0)
 }
 
+// MockRefreshable is a MockCleanable validating resources against what was listed
+type MockRefreshable struct {
+	MockCleanable
+}
+
+func (m *MockRefreshable) Refresh(ctx context.Context, resources []string) error {
+	args := m.Called(ctx, resources)
+	return args.Error(0)
+}
+
+// MockTaggable is a MockCleanable whose resources can be tagged
+type MockTaggable struct {
+	MockCleanable
+}
+
+func (m *MockTaggable) Tag(ctx context.Context, resource, key, value string) error {
+	args := m.Called(ctx, resource, key, value)
+	return args.Error(0)
+}
+
+func (m *MockTaggable) Untag(ctx context.Context, resource, key string) error {
+	args := m.Called(ctx, resource, key)
+	return args.Error(0)
+}
+
+// MockFilterable is a MockCleanable whose resources can be filtered while listing them
+type MockFilterable struct {
+	MockCleanable
+}
+
+func (m *MockFilterable) Filter(fields map[string]string) {
+	m.Called(fields)
+}
+
+// MockMetadataChecker is a MockCleanable whose checks only look at the state of its resources
+type MockMetadataChecker struct {
+	MockCleanable
+}
+
+func (m *MockMetadataChecker) CheckedAttributes() []string {
+	return []string{"state"}
+}
+
 // Read output and unmarshall the JSON log into a log struct
 func getLastLogLine(logs string) (string, error) {
 	log := new(LogOutput)
@@ -71,7 +135,7 @@ func TestList(t *testing.T) {
 		"Successful list all resources": {
 			input: "TestService",
 			helpers: func() {
-				mockService.On("List", ctx).Return([]string{"res1", "res2"}, nil)
+				mockService.On("List", mock.Anything).Return([]string{"res1", "res2"}, nil)
 			},
 			testCase: func(t *testing.T, output string, err error) {
 				log, testErr := getLastLogLine(output)
@@ -86,7 +150,7 @@ func TestList(t *testing.T) {
 		"List returns an error": {
 			input: "TestService",
 			helpers: func() {
-				mockService.On("List", ctx).Return([]string{}, errors.New("list error"))
+				mockService.On("List", mock.Anything).Return([]string{}, errors.New("list error"))
 			},
 			testCase: func(t *testing.T, output string, err error) {
 				assert.EqualError(t, err, "error listing resources for service 'TestService': list error")
@@ -100,7 +164,7 @@ func TestList(t *testing.T) {
 
 			test.helpers()
 
-			err := list(ctx, mockService, test.input)
+			_, err := list(ctx, mockService, test.input)
 			output := buf.String()
 
 			test.testCase(t, output, err)
@@ -128,8 +192,8 @@ func TestValidate(t *testing.T) {
 		"Successful validation of all resources": {
 			input: "TestService",
 			helpers: func() {
-				mockService.On("List", ctx).Return([]string{"res1"}, nil)
-				mockService.On("Validate", ctx, "res1").Return(true, nil)
+				mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+				mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
 			},
 			testCase: func(t *testing.T, output string, err error) {
 				assert.Nil(t, err)
@@ -138,8 +202,8 @@ func TestValidate(t *testing.T) {
 		"Validation returns an error": {
 			input: "TestService",
 			helpers: func() {
-				mockService.On("List", ctx).Return([]string{"res1"}, nil)
-				mockService.On("Validate", ctx, "res1").Return(false, errors.New("validation error"))
+				mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+				mockService.On("Validate", mock.Anything, "res1").Return(false, errors.New("validation error"))
 			},
 			testCase: func(t *testing.T, output string, err error) {
 				assert.EqualError(t, err, "error validating resource 'res1' in service 'TestService': validation error")
@@ -148,8 +212,8 @@ func TestValidate(t *testing.T) {
 		"Resource is deletable": {
 			input: "TestService",
 			helpers: func() {
-				mockService.On("List", ctx).Return([]string{"res1"}, nil)
-				mockService.On("Validate", ctx, "res1").Return(true, nil)
+				mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+				mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
 			},
 			testCase: func(t *testing.T, output string, err error) {
 				log, testErr := getLastLogLine(output)
@@ -161,11 +225,28 @@ func TestValidate(t *testing.T) {
 				assert.Nil(t, err)
 			},
 		},
+		"Resource could not be evaluated": {
+			input: "TestService",
+			helpers: func() {
+				mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+				mockService.On("Validate", mock.Anything, "res1").Return(resource.NewVerdict(resource.Reason{Rule: "found", Decision: resource.Unknown, Message: "resource was not found"}), nil)
+			},
+			testCase: func(t *testing.T, output string, err error) {
+				log, testErr := getLastLogLine(output)
+				if testErr != nil {
+					require.NoError(t, testErr)
+				}
+
+				assert.Equal(t, "Resource 'res1' in service 'TestService' could not be evaluated and cannot be excluded.", log)
+				assert.Contains(t, output, `"decision":"unknown","reasons":"resource was not found"`)
+				assert.Nil(t, err)
+			},
+		},
 		"Resource is not deletable": {
 			input: "TestService",
 			helpers: func() {
-				mockService.On("List", ctx).Return([]string{"res1"}, nil)
-				mockService.On("Validate", ctx, "res1").Return(false, nil)
+				mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+				mockService.On("Validate", mock.Anything, "res1").Return(false, nil)
 			},
 			testCase: func(t *testing.T, output string, err error) {
 				log, testErr := getLastLogLine(output)
@@ -185,7 +266,7 @@ func TestValidate(t *testing.T) {
 
 			test.helpers()
 
-			err := validate(ctx, mockService, test.input)
+			_, err := validate(ctx, mockService, test.input)
 			output := buf.String()
 
 			test.testCase(t, output, err)
@@ -213,9 +294,9 @@ func TestDelete(t *testing.T) {
 		"Successful deletion of all resources": {
 			input: "TestService",
 			helpers: func() {
-				mockService.On("List", ctx).Return([]string{"res1"}, nil)
-				mockService.On("Validate", ctx, "res1").Return(true, nil)
-				mockService.On("Delete", ctx, "res1").Return(nil)
+				mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+				mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
+				mockService.On("Delete", mock.Anything, "res1").Return(nil)
 			},
 			testCase: func(t *testing.T, output string, err error) {
 				log, testErr := getLastLogLine(output)
@@ -230,9 +311,9 @@ func TestDelete(t *testing.T) {
 		"Deletion fails for a resource": {
 			input: "TestService",
 			helpers: func() {
-				mockService.On("List", ctx).Return([]string{"res1"}, nil)
-				mockService.On("Validate", ctx, "res1").Return(true, nil)
-				mockService.On("Delete", ctx, "res1").Return(errors.New("delete error"))
+				mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+				mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
+				mockService.On("Delete", mock.Anything, "res1").Return(errors.New("delete error"))
 			},
 			testCase: func(t *testing.T, output string, err error) {
 				assert.EqualError(t, err, "error deleting resource 'res1' in service 'TestService': delete error")
@@ -246,7 +327,221 @@ func TestDelete(t *testing.T) {
 
 			test.helpers()
 
-			err := delete(ctx, mockService, test.input)
+			_, err := delete(ctx, mockService, test.input, deletionLimits{})
+			output := buf.String()
+
+			test.testCase(t, output, err)
+
+			buf.Reset()
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteRefreshesEmptyResources(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+
+	t.Run("Empty resources are refreshed and validated again", func(t *testing.T) {
+		mockService := new(MockRefreshable)
+		mockService.On("List", mock.Anything).Return([]string{"res1", "res2", "res3"}, nil)
+		mockService.On("Validate", mock.Anything, "res1").Return(true, nil).Once()
+		mockService.On("Validate", mock.Anything, "res2").Return(true, nil).Once()
+		mockService.On("Validate", mock.Anything, "res3").Return(false, nil).Once()
+		mockService.On("Refresh", mock.Anything, []string{"res1", "res2"}).Return(nil).Once()
+		// res1 started being used after it was listed
+		mockService.On("Validate", mock.Anything, "res1").Return(false, nil).Once()
+		mockService.On("Validate", mock.Anything, "res2").Return(true, nil).Once()
+		mockService.On("Delete", mock.Anything, "res2").Return(nil).Once()
+
+		service := providers.Service{Name: "TestService", Cleanable: mockService}
+		sum, err := delete(context.Background(), service, service.String(), deletionLimits{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sum.succeeded)
+		assert.Equal(t, 2, sum.skipped)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Resources aren't validated twice without a refresh", func(t *testing.T) {
+		mockService := new(MockCleanable)
+		mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+		mockService.On("Validate", mock.Anything, "res1").Return(true, nil).Once()
+		mockService.On("Delete", mock.Anything, "res1").Return(nil).Once()
+
+		_, err := delete(context.Background(), mockService, "TestService", deletionLimits{})
+
+		assert.NoError(t, err)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestPlan(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+	mockService := new(MockCleanable)
+
+	// Initialize logger and set it to output to a buffer
+	logger.InitializeLogger("info", "json", &buf)
+
+	// Test cases
+	cases := map[string]struct {
+		input    string
+		helpers  func()
+		testCase func(*testing.T, []planEntry, error)
+	}{
+		"Only empty resources are added to the plan": {
+			input: "TestService",
+			helpers: func() {
+				mockService.On("List", mock.Anything).Return([]string{"res1", "res2"}, nil)
+				mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
+				mockService.On("Validate", mock.Anything, "res2").Return(false, nil)
+			},
+			testCase: func(t *testing.T, entries []planEntry, err error) {
+				require.NoError(t, err)
+				require.Len(t, entries, 1)
+				assert.Equal(t, "res1", entries[0].ID)
+				assert.NotEmpty(t, entries[0].Reason)
+			},
+		},
+		"Validation returns an error": {
+			input: "TestService",
+			helpers: func() {
+				mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+				mockService.On("Validate", mock.Anything, "res1").Return(false, errors.New("validation error"))
+			},
+			testCase: func(t *testing.T, entries []planEntry, err error) {
+				assert.Nil(t, entries)
+				assert.EqualError(t, err, "error validating resource 'res1' in service 'TestService': validation error")
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockService.ExpectedCalls = nil
+
+			test.helpers()
+
+			entries, _, err := planService(ctx, mockService, test.input)
+			test.testCase(t, entries, err)
+
+			buf.Reset()
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPlanFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("Plan is read back exactly as it was written", func(t *testing.T) {
+		path := filepath.Join(dir, "plan.json")
+		p := &plan{
+			Version:   planVersion,
+			CreatedAt: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC),
+			Provider:  "aws",
+			Services:  []string{"ebs"},
+			Resources: []planEntry{{Service: "ebs", Account: "123456789012", Region: "us-east-1", ID: "vol-1", Reason: "resource is not being used", ValidatedAt: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)}},
+		}
+
+		require.NoError(t, writePlan(path, p))
+
+		read, err := readPlan(path)
+		require.NoError(t, err)
+		assert.Equal(t, p, read)
+	})
+
+	t.Run("Plan with resources in accounts or regions that aren't loaded is rejected", func(t *testing.T) {
+		p := &plan{
+			Services: []string{"ebs"},
+			Resources: []planEntry{
+				{Service: "ebs", Account: "111111111111", Region: "us-east-1", ID: "vol-1"},
+				{Service: "ebs", Account: "222222222222", Region: "us-east-1", ID: "vol-2"},
+			},
+		}
+		services := []providers.Service{{Name: "ebs", Account: "111111111111", Region: "us-east-1"}}
+
+		assert.EqualError(t, p.checkTargets(services), "plan has resource 'vol-2' of service 'ebs' in account '222222222222' and region 'us-east-1', but they're not configured")
+		assert.Len(t, p.entries(services[0]), 1)
+	})
+
+	t.Run("Plan with an unsupported version is rejected", func(t *testing.T) {
+		path := filepath.Join(dir, "old.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"version": 0, "provider": "aws", "services": ["ebs"]}`), 0o600))
+
+		_, err := readPlan(path)
+		assert.EqualError(t, err, fmt.Sprintf("plan file '%s' has version 0, but only version %d is supported", path, planVersion))
+	})
+}
+
+func TestApply(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+	mockService := new(MockCleanable)
+
+	// Initialize logger and set it to output to a buffer
+	logger.InitializeLogger("info", "json", &buf)
+
+	entries := []planEntry{{Service: "TestService", ID: "res1"}, {Service: "TestService", ID: "res2"}}
+
+	// Test cases
+	cases := map[string]struct {
+		limits   deletionLimits
+		helpers  func()
+		testCase func(*testing.T, string, error)
+	}{
+		"Resources that are still empty are deleted": {
+			helpers: func() {
+				mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
+				mockService.On("Validate", mock.Anything, "res2").Return(true, nil)
+				mockService.On("Delete", mock.Anything, "res1").Return(nil)
+				mockService.On("Delete", mock.Anything, "res2").Return(nil)
+			},
+			testCase: func(t *testing.T, output string, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		"Resources that are not empty anymore are skipped": {
+			helpers: func() {
+				mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
+				mockService.On("Validate", mock.Anything, "res2").Return(false, nil)
+				mockService.On("Delete", mock.Anything, "res1").Return(nil)
+			},
+			testCase: func(t *testing.T, output string, err error) {
+				log, testErr := getLastLogLine(output)
+				if testErr != nil {
+					require.NoError(t, testErr)
+				}
+
+				assert.Equal(t, "Resource 'res2' in service 'TestService' is not empty anymore and will be skipped.", log)
+				assert.Nil(t, err)
+			},
+		},
+		"Plans with more entries than the maximum number of deletions are refused": {
+			limits:  deletionLimits{maxDeletions: 1},
+			helpers: func() {},
+			testCase: func(t *testing.T, output string, err error) {
+				assert.EqualError(t, err, "2 resource(s) of service 'TestService' would be deleted, more than the maximum of 1: no resource was deleted")
+			},
+		},
+		"Plans with more entries than the maximum ratio of deletions are refused": {
+			limits: deletionLimits{maxRatio: 0.5},
+			helpers: func() {
+				mockService.On("List", mock.Anything).Return([]resource.Resource{{ID: "res1"}, {ID: "res2"}, {ID: "res3"}}, nil)
+			},
+			testCase: func(t *testing.T, output string, err error) {
+				assert.EqualError(t, err, "2 out of 3 resource(s) of service 'TestService' would be deleted, more than the maximum ratio of 0.5: no resource was deleted")
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockService.ExpectedCalls = nil
+
+			test.helpers()
+
+			_, err := apply(ctx, mockService, "TestService", entries, test.limits)
 			output := buf.String()
 
 			test.testCase(t, output, err)
@@ -256,3 +551,1197 @@ func TestDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestExplain(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+
+	mockService := new(MockCleanable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{
+		{ID: "vol-1"},
+		{
+			ID:         "vol-2",
+			Name:       "data",
+			Type:       "AWS::EC2::Volume",
+			Tags:       map[string]string{"cleanup-ignore": "true", "Name": "data"},
+			Attributes: map[string]string{"state": "available"},
+		},
+	}, nil)
+	mockService.On("Validate", mock.Anything, "vol-2").Return(resource.NewVerdict(
+		resource.Reason{Rule: "state", Decision: resource.Deletable, Message: "volume is available", Evidence: map[string]string{"state": "available"}},
+		resource.Reason{Rule: "ignore-tag", Decision: resource.Keep, Message: "volume has the cleanup-ignore=true tag", Evidence: map[string]string{"cleanup-ignore": "true"}},
+	), nil)
+
+	t.Run("Every rule is explained", func(t *testing.T) {
+		e, err := explain(context.Background(), mockService, "TestService", "vol-2")
+		require.NoError(t, err)
+		require.NotNil(t, e)
+
+		var buf bytes.Buffer
+		require.NoError(t, e.write(&buf, false))
+		assert.Equal(t, `Resource:   vol-2 (data)
+Service:    TestService
+Type:       AWS::EC2::Volume
+Tags:       Name=data, cleanup-ignore=true
+Attributes: state=available
+Decision:   keep
+Rules:
+  - state: deletable (volume is available)
+    looked at: state=available
+  - ignore-tag: keep (volume has the cleanup-ignore=true tag)
+    looked at: cleanup-ignore=true
+`, buf.String())
+
+		buf.Reset()
+		require.NoError(t, e.write(&buf, true))
+		decoded := new(explanation)
+		require.NoError(t, json.Unmarshal(buf.Bytes(), decoded))
+		assert.Equal(t, e.Verdict, decoded.Verdict)
+	})
+
+	t.Run("Resource not found", func(t *testing.T) {
+		e, err := explain(context.Background(), mockService, "TestService", "vol-3")
+
+		assert.NoError(t, err)
+		assert.Nil(t, e)
+	})
+}
+
+func TestLoadTargets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ids.txt")
+	require.NoError(t, os.WriteFile(file, []byte("vol-2\n\n# reviewed by the team\n  vol-3  \n"), 0o600))
+
+	cases := map[string]struct {
+		ids      []string
+		from     string
+		stdin    string
+		expected []string
+		err      string
+	}{
+		"No flags target every resource": {},
+		"IDs from flags": {
+			ids:      []string{"vol-1"},
+			expected: []string{"vol-1"},
+		},
+		"IDs from flags and a file": {
+			ids:      []string{"vol-1"},
+			from:     file,
+			expected: []string{"vol-1", "vol-2", "vol-3"},
+		},
+		"IDs from stdin": {
+			from:     "-",
+			stdin:    "vol-4\nvol-5\n",
+			expected: []string{"vol-4", "vol-5"},
+		},
+		"Empty stdin": {
+			from: "-",
+			err:  "no resource IDs were found in '-'",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func(original io.Reader) { stdin = original }(stdin)
+			stdin = strings.NewReader(test.stdin)
+
+			loaded, err := loadTargets(test.ids, test.from)
+
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			if test.expected == nil {
+				assert.Nil(t, loaded)
+				return
+			}
+			assert.Equal(t, test.expected, loaded.ids)
+		})
+	}
+}
+
+func TestDeleteTargets(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer func() { targets = nil }()
+
+	mockService := new(MockCleanable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{
+		{ID: "res1"},
+		{ID: "res2", ARN: "arn:res2"},
+		{ID: "res3"},
+	}, nil)
+	mockService.On("Validate", mock.Anything, "res2").Return(false, nil).Once()
+	mockService.On("Validate", mock.Anything, "res3").Return(true, nil).Once()
+	mockService.On("Delete", mock.Anything, "res3").Return(nil).Once()
+
+	targets = &targetSet{ids: []string{"arn:res2", "res3", "res4"}, matched: map[string]bool{}}
+	sum, err := delete(context.Background(), mockService, "TestService", deletionLimits{})
+
+	// Only the targeted resources are validated and deleted, and the unknown ones are refused
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sum.succeeded)
+	assert.EqualError(t, targets.err("TestService"), "resource(s) res4 don't belong to service(s) 'TestService' and were refused")
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteLimits(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+
+	cases := map[string]struct {
+		limits   deletionLimits
+		expected string
+	}{
+		"No limits": {
+			limits: deletionLimits{},
+		},
+		"Within the limits": {
+			limits: deletionLimits{maxDeletions: 2, maxRatio: 0.5},
+		},
+		"Too many deletions": {
+			limits:   deletionLimits{maxDeletions: 1},
+			expected: "2 resource(s) of service 'TestService' would be deleted, more than the maximum of 1: no resource was deleted",
+		},
+		"Ratio of deletions too high": {
+			limits:   deletionLimits{maxRatio: 0.25},
+			expected: "2 out of 4 resource(s) of service 'TestService' would be deleted, more than the maximum ratio of 0.25: no resource was deleted",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockService := new(MockCleanable)
+			mockService.On("List", mock.Anything).Return([]string{"res1", "res2", "res3", "res4"}, nil)
+			mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
+			mockService.On("Validate", mock.Anything, "res2").Return(true, nil)
+			mockService.On("Validate", mock.Anything, "res3").Return(false, nil)
+			mockService.On("Validate", mock.Anything, "res4").Return(false, nil)
+			if test.expected == "" {
+				mockService.On("Delete", mock.Anything, "res1").Return(nil).Once()
+				mockService.On("Delete", mock.Anything, "res2").Return(nil).Once()
+			}
+
+			sum, err := delete(context.Background(), mockService, "TestService", test.limits)
+
+			if test.expected != "" {
+				assert.EqualError(t, err, test.expected)
+				assert.Equal(t, 0, sum.succeeded)
+				mockService.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 2, sum.succeeded)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	resources := []resource.Resource{{ID: "res1", Name: "data"}, {ID: "res2"}, {ID: "res3"}, {ID: "res4"}}
+	results := make([]deletion, len(resources))
+	candidates := []int{0, 2, 3}
+
+	cases := map[string]struct {
+		input    string
+		expected []int
+		invalid  bool
+	}{
+		"Confirm all of them": {
+			input:    "a\n",
+			expected: candidates,
+		},
+		"Pick some of them": {
+			input:    "1, 2-3\n",
+			expected: []int{0, 2, 3},
+		},
+		"Pick a single one": {
+			input:    "2\n",
+			expected: []int{2},
+		},
+		"Abort": {
+			input:    "n\n",
+			expected: nil,
+		},
+		"Abort when input runs out": {
+			input:    "",
+			expected: nil,
+		},
+		"Ask again after an invalid answer": {
+			input:    "4\n3\n",
+			expected: []int{3},
+			invalid:  true,
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := &prompt{in: bufio.NewReader(strings.NewReader(test.input)), out: &out}
+
+			picked, err := p.pick("TestService", resources, results, candidates)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, picked)
+			assert.Contains(t, out.String(), "3 resource(s) of service 'TestService' can be deleted:")
+			assert.Equal(t, test.invalid, strings.Contains(out.String(), "Invalid answer: '4' is out of range, resources go from 1 to 3"))
+		})
+	}
+
+	t.Run("Nil prompt picks every candidate", func(t *testing.T) {
+		var p *prompt
+		picked, err := p.pick("TestService", resources, results, candidates)
+
+		require.NoError(t, err)
+		assert.Equal(t, candidates, picked)
+	})
+}
+
+func TestDeletePicked(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer func() { picker = nil }()
+
+	mockService := new(MockCleanable)
+	mockService.On("List", mock.Anything).Return([]string{"res1", "res2", "res3"}, nil)
+	mockService.On("Validate", mock.Anything, "res1").Return(true, nil).Once()
+	mockService.On("Validate", mock.Anything, "res2").Return(false, nil).Once()
+	mockService.On("Validate", mock.Anything, "res3").Return(true, nil).Once()
+	mockService.On("Delete", mock.Anything, "res3").Return(nil).Once()
+
+	picker = &prompt{in: bufio.NewReader(strings.NewReader("2\n")), out: io.Discard}
+	sum, err := delete(context.Background(), mockService, "TestService", deletionLimits{})
+
+	// Only the resource picked by the operator is deleted
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sum.succeeded)
+	assert.Equal(t, 2, sum.skipped)
+	mockService.AssertExpectations(t)
+}
+
+func TestMark(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	marked := map[string]string{resource.MarkTag: "2024-05-01T12:00:00Z"}
+
+	mockService := new(MockTaggable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{
+		{ID: "res1"},
+		{ID: "res2", Tags: marked},
+		{ID: "res3", Tags: marked},
+		{ID: "res4"},
+	}, nil)
+	mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "res2").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "res3").Return(false, nil)
+	mockService.On("Validate", mock.Anything, "res4").Return(false, nil)
+	mockService.On("Tag", mock.Anything, "res1", resource.MarkTag, now.Format(time.RFC3339)).Return(nil).Once()
+	mockService.On("Untag", mock.Anything, "res3", resource.MarkTag).Return(nil).Once()
+
+	sum, err := mark(context.Background(), mockService, "TestService", now)
+
+	// Unused resources are marked unless they already were, and the ones in use again are unmarked
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sum.succeeded)
+	assert.Equal(t, 2, sum.skipped)
+	outcomes := make([]outcome, len(sum.results))
+	for i, r := range sum.results {
+		outcomes[i] = r.Outcome
+	}
+	assert.Equal(t, []outcome{outcomeMarked, outcomeMarked, outcomeUnmarked, outcomeKept}, outcomes)
+	mockService.AssertExpectations(t)
+
+	t.Run("Services that can't mark resources", func(t *testing.T) {
+		_, err := mark(context.Background(), new(MockCleanable), "TestService", now)
+		assert.EqualError(t, err, "service 'TestService' doesn't support marking resources")
+	})
+}
+
+func TestSweepMarked(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	mockService := new(MockTaggable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{
+		{ID: "res1"},
+		{ID: "res2", Tags: map[string]string{resource.MarkTag: "2024-05-08T12:00:00Z"}},
+		{ID: "res3", Tags: map[string]string{resource.MarkTag: "2024-05-01T12:00:00Z"}},
+		{ID: "res4", Tags: map[string]string{resource.MarkTag: "2024-05-01T12:00:00Z"}},
+	}, nil)
+	mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "res2").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "res3").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "res4").Return(false, nil)
+	mockService.On("Untag", mock.Anything, "res4", resource.MarkTag).Return(nil).Once()
+	mockService.On("Delete", mock.Anything, "res3").Return(nil).Once()
+
+	service := providers.Service{Name: "TestService", Cleanable: mockService}
+	marked, err := withGracePeriod(service, service.String(), 7*24*time.Hour, now)
+	require.NoError(t, err)
+	sum, err := delete(context.Background(), marked, service.String(), deletionLimits{})
+
+	// Only the resource marked longer than the grace period ago is deleted, and the one in use again is unmarked
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sum.succeeded)
+	assert.Equal(t, 3, sum.skipped)
+	assert.Equal(t, "empty: resource is empty; grace-period: resource wasn't marked as unused by a previous run", joinReasons(sum.results[0].Reasons))
+	assert.Equal(t, "empty: resource is empty; grace-period: resource was marked as unused 2d ago, before the grace period of 7d elapsed", joinReasons(sum.results[1].Reasons))
+	assert.Equal(t, outcomeDeleted, sum.results[2].Outcome)
+	assert.Equal(t, "empty: resource is not empty; mark: resource is in use again, so its mark was removed", joinReasons(sum.results[3].Reasons))
+	mockService.AssertExpectations(t)
+}
+
+// Rules and messages of the reasons passed as parameter, in a single line
+func joinReasons(reasons []resource.Reason) string {
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = reason.Rule + ": " + reason.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+func TestDeleteIdlePolicy(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	store, err := state.Load(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mockService := new(MockCleanable)
+	mockService.On("List", mock.Anything).Return([]string{"res1"}, nil)
+	mockService.On("Validate", mock.Anything, "res1").Return(true, nil)
+	service := providers.Service{Name: "TestService", Cleanable: mockService}
+	policy := idlePolicy{minRuns: 2, minDuration: time.Hour}
+
+	// The resource was only seen unused once, so it's kept
+	sum, err := delete(context.Background(), withState(service, store, policy, first), service.String(), deletionLimits{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sum.succeeded)
+	assert.Equal(t, "resource was seen unused in 1 run(s) over 0s, fewer than the 2 run(s) required", sum.results[0].Reasons[1].Message)
+
+	// Seen unused again, but not for long enough
+	sum, err = delete(context.Background(), withState(service, store, policy, first.Add(time.Minute)), service.String(), deletionLimits{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sum.succeeded)
+	assert.Equal(t, "resource was seen unused in 2 run(s) over 1m0s, less than the 1h0m0s required", sum.results[0].Reasons[1].Message)
+
+	// Unused across enough runs and for long enough
+	mockService.On("Delete", mock.Anything, "res1").Return(nil).Once()
+	sum, err = delete(context.Background(), withState(service, store, policy, first.Add(2*time.Hour)), service.String(), deletionLimits{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sum.succeeded)
+	assert.Equal(t, 3, store.Resources["TestService///res1"].IdleRuns)
+	mockService.AssertExpectations(t)
+}
+
+func TestMinAge(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer viper.Reset()
+	viper.Set("services.ebs.min_age", "1h")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mockService := new(MockCleanable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{
+		{ID: "vol-1", CreatedAt: now.Add(-10 * time.Minute)},
+		{ID: "vol-2", CreatedAt: now.Add(-3 * time.Hour)},
+		{ID: "vol-3", CreatedAt: now.Add(-10 * time.Minute)},
+	}, nil)
+	mockService.On("Validate", mock.Anything, "vol-1").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "vol-2").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "vol-3").Return(false, nil)
+
+	services := []providers.Service{{Name: "ebs", Cleanable: mockService}, {Name: "eni", Cleanable: mockService}}
+	require.NoError(t, withMinAge(services, now, nil))
+	assert.IsType(t, &agedService{}, services[0].Cleanable)
+	assert.Equal(t, mockService, services[1].Cleanable)
+
+	sum, err := validate(context.Background(), services[0], services[0].String())
+
+	// Only the resource older than the minimum age can be deleted
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sum.succeeded)
+	decisions := make([]resource.Decision, len(sum.results))
+	for i, r := range sum.results {
+		decisions[i] = r.Decision
+	}
+	assert.Equal(t, []resource.Decision{resource.Keep, resource.Deletable, resource.Keep}, decisions)
+	assert.Equal(t, "resource was created 10m0s ago, less than the minimum age of 1h0m0s", sum.results[0].Reasons[1].Message)
+	assert.Equal(t, minAgeRule, sum.results[2].Reasons[1].Rule)
+	assert.NotEmpty(t, sum.results[0].Age)
+	mockService.AssertExpectations(t)
+}
+
+func TestMinAgeFirstSeen(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer viper.Reset()
+	viper.Set("services.eni.min_age", "1h")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// ENIs don't tell when they were created
+	mockService := new(MockCleanable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{{ID: "eni-1"}, {ID: "eni-2"}}, nil)
+	mockService.On("Validate", mock.Anything, mock.Anything).Return(true, nil)
+
+	// Without a state file, their age can't be told at all
+	services := []providers.Service{{Name: "eni", Cleanable: mockService}}
+	require.NoError(t, withMinAge(services, now, nil))
+	_, err := validate(context.Background(), services[0], services[0].String())
+	assert.EqualError(t, err, "error listing resources for service 'eni': minimum age of service 'eni' requires a state file, since its resources don't tell when they were created")
+
+	// With a state file, they're aged from when they were first seen
+	store, err := state.Load(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	store.FirstSeen("eni///eni-1", now.Add(-3*time.Hour))
+
+	services = []providers.Service{{Name: "eni", Cleanable: mockService}}
+	require.NoError(t, withMinAge(services, now, store))
+	sum, err := validate(context.Background(), services[0], services[0].String())
+
+	assert.NoError(t, err)
+	decisions := make([]resource.Decision, len(sum.results))
+	for i, r := range sum.results {
+		decisions[i] = r.Decision
+	}
+	assert.Equal(t, []resource.Decision{resource.Deletable, resource.Keep}, decisions)
+	assert.Equal(t, "resource was first seen 3h0m0s ago, older than the minimum age of 1h0m0s", sum.results[0].Reasons[1].Message)
+	assert.Equal(t, "resource was first seen 0s ago, less than the minimum age of 1h0m0s", sum.results[1].Reasons[1].Message)
+	assert.Equal(t, "3h0m0s", sum.results[0].Age)
+	assert.Equal(t, "0s", sum.results[1].Age)
+	assert.Equal(t, now, store.Seen["eni///eni-2"])
+}
+
+func TestProtection(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer viper.Reset()
+
+	listed := []resource.Resource{
+		{ID: "res1", Tags: map[string]string{"cleanup-ignore": "true"}},
+		{ID: "res2", Tags: map[string]string{"environment": "prod"}},
+		{ID: "res3", Tags: map[string]string{"environment": "dev", "owner": "finops"}},
+	}
+
+	cases := map[string]struct {
+		tags     []string
+		expected []resource.Decision
+		reason   string
+		err      string
+	}{
+		"Default protection tag": {
+			expected: []resource.Decision{resource.Keep, resource.Deletable, resource.Deletable},
+			reason:   "resource has the cleanup-ignore=true protection tag",
+		},
+		"Configured protection tags": {
+			tags:     []string{"environment=prod", "owner"},
+			expected: []resource.Decision{resource.Deletable, resource.Keep, resource.Keep},
+			reason:   "resource has the environment=prod protection tag",
+		},
+		"Invalid protection tag": {
+			tags: []string{"=prod"},
+			err:  "protection tag '=prod' must be either a key or a key=value pair",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			if test.tags != nil {
+				viper.Set("protection_tags", test.tags)
+			}
+
+			mockService := new(MockCleanable)
+			mockService.On("List", mock.Anything).Return(listed, nil)
+			mockService.On("Validate", mock.Anything, mock.Anything).Return(true, nil)
+			services := []providers.Service{{Name: "TestService", Cleanable: mockService}}
+
+			err := withProtection(services, time.Now())
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			// Resources are looked up by listing the service when they weren't listed before
+			decisions, reasons := make([]resource.Decision, len(listed)), []string{}
+			for i, res := range listed {
+				verdict, err := services[0].Validate(context.Background(), res.ID)
+				require.NoError(t, err)
+				decisions[i] = verdict.Decision
+				if verdict.Decision == resource.Keep {
+					reasons = append(reasons, verdict.String())
+				}
+			}
+			assert.Equal(t, test.expected, decisions)
+			assert.Equal(t, test.reason, reasons[0])
+			mockService.AssertNumberOfCalls(t, "List", 1)
+		})
+	}
+}
+
+func TestListFiltered(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer func() { filterExpr = nil }()
+
+	var err error
+	filterExpr, err = loadFilter(`tag:team == "data" && size > 100`)
+	require.NoError(t, err)
+
+	// The equality is pushed down to the service, and the resources it returns are matched against the whole filter
+	mockService := new(MockFilterable)
+	mockService.On("Filter", map[string]string{"tag:team": "data"}).Once()
+	mockService.On("List", mock.Anything).Return([]resource.Resource{
+		{ID: "vol-1", Tags: map[string]string{"team": "data"}, Attributes: map[string]string{"size": "500"}},
+		{ID: "vol-2", Tags: map[string]string{"team": "data"}, Attributes: map[string]string{"size": "8"}},
+	}, nil)
+
+	sum, err := list(context.Background(), mockService, "TestService")
+
+	assert.NoError(t, err)
+	assert.Len(t, sum.results, 1)
+	assert.Equal(t, "vol-1", sum.results[0].ID)
+	mockService.AssertExpectations(t)
+
+	_, err = loadFilter("size >")
+	assert.EqualError(t, err, "invalid filter 'size >': expected a value after '>' at position 7")
+}
+
+func TestResourceLists(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer viper.Reset()
+
+	listed := []resource.Resource{
+		{ID: "vol-1", Name: "dr-database"},
+		{ID: "vol-2", Name: "scratch"},
+		{ID: "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/reserved/73e2d6bc24d8a067"},
+		{ID: "vol-4", Name: "data-backup"},
+	}
+
+	cases := map[string]struct {
+		exclude  []string
+		include  []string
+		file     string
+		expected []resource.Decision
+		err      string
+	}{
+		"Excluded IDs, globs and regular expressions": {
+			exclude:  []string{"vol-2", "*/reserved/*", "/^dr-/"},
+			expected: []resource.Decision{resource.Keep, resource.Keep, resource.Keep, resource.Deletable},
+		},
+		"Included resources are considered even when they're excluded": {
+			include:  []string{"vol-1"},
+			exclude:  []string{"dr-*", "vol-2"},
+			expected: []resource.Decision{resource.Deletable, resource.Keep, resource.Deletable, resource.Deletable},
+		},
+		"Including resources doesn't skip the other ones": {
+			include:  []string{"*-backup"},
+			expected: []resource.Decision{resource.Deletable, resource.Deletable, resource.Deletable, resource.Deletable},
+		},
+		"Service scoped to the included resources by excluding every other one": {
+			include:  []string{"*-backup"},
+			exclude:  []string{"*"},
+			expected: []resource.Decision{resource.Keep, resource.Keep, resource.Keep, resource.Deletable},
+		},
+		"Lists from the resource lists file are merged": {
+			exclude:  []string{"vol-2"},
+			file:     "services:\n  TestService:\n    exclude:\n      - vol-4\n",
+			expected: []resource.Decision{resource.Deletable, resource.Keep, resource.Deletable, resource.Keep},
+		},
+		"Invalid regular expression": {
+			exclude: []string{"/dr-(/"},
+			err:     "invalid exclude pattern of service 'TestService': invalid regular expression '/dr-(/': error parsing regexp: missing closing ): `dr-(`",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			viper.Set("services.TestService.exclude", test.exclude)
+			viper.Set("services.TestService.include", test.include)
+			if test.file != "" {
+				path := filepath.Join(t.TempDir(), "lists.yaml")
+				require.NoError(t, os.WriteFile(path, []byte(test.file), 0o600))
+				viper.Set("resource_lists_file", path)
+			}
+
+			mockService := new(MockCleanable)
+			mockService.On("List", mock.Anything).Return(listed, nil)
+			mockService.On("Validate", mock.Anything, mock.Anything).Return(true, nil)
+			mockService.On("Delete", mock.Anything, mock.Anything).Return(nil)
+			services := []providers.Service{{Name: "TestService", Cleanable: mockService}}
+
+			err := withProtection(services, time.Now())
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			decisions := make([]resource.Decision, len(listed))
+			for i, res := range listed {
+				verdict, err := services[0].Validate(context.Background(), res.ID)
+				require.NoError(t, err)
+				decisions[i] = verdict.Decision
+
+				// Skipped resources can't be deleted even without being validated first
+				err = services[0].Delete(context.Background(), res.ID)
+				if verdict.Decision == resource.Keep {
+					assert.ErrorContains(t, err, "is protected from being deleted")
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+			assert.Equal(t, test.expected, decisions)
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer viper.Reset()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	listed := []resource.Resource{
+		{ID: "vol-1", CreatedAt: now.Add(-30 * 24 * time.Hour), Attributes: map[string]string{"state": "available"}},
+		{ID: "vol-2", CreatedAt: now.Add(-2 * 24 * time.Hour), Attributes: map[string]string{"state": "available"}},
+		{ID: "vol-3", CreatedAt: now.Add(-30 * 24 * time.Hour), Attributes: map[string]string{"state": "in-use"}, Tags: map[string]string{"owner": "data"}},
+	}
+
+	cases := map[string]struct {
+		policy   string
+		opaque   bool
+		expected []resource.Decision
+		err      string
+	}{
+		"Rules are evaluated along with the checks of the service": {
+			policy:   "services:\n  ebs:\n    rules:\n      - name: recent\n        decision: keep\n        when:\n          younger_than: 7d\n",
+			expected: []resource.Decision{resource.Deletable, resource.Keep, resource.Keep},
+		},
+		"Rules replace the checks of the service": {
+			policy: "services:\n  ebs:\n    replace: true\n    rules:\n" +
+				"      - name: unused\n        decision: deletable\n        otherwise: keep\n        when:\n          all:\n            - attribute: state\n              in: [available, in-use]\n            - older_than: 7d\n" +
+				"      - name: owned\n        decision: keep\n        when:\n          tag: owner\n          exists: true\n",
+			expected: []resource.Decision{resource.Deletable, resource.Keep, resource.Keep},
+		},
+		"Invalid policy file": {
+			policy: "services:\n  ebs:\n    rules:\n      - name: recent\n        decision: delete\n        when:\n          younger_than: 7d\n",
+			err:    "error checking policy file: invalid rule 1 of service 'ebs': decision must be deletable, keep or unknown, got: 'delete'",
+		},
+		"Rules replacing checks without looking at what they check": {
+			policy: "services:\n  ebs:\n    replace: true\n    rules:\n      - name: old\n        decision: deletable\n        when:\n          older_than: 7d\n",
+			err:    "rules replacing the checks of service 'ebs' must look at its state attribute(s)",
+		},
+		"Rules replacing checks that look at more than the metadata": {
+			policy: "services:\n  ebs:\n    replace: true\n    rules:\n      - name: available\n        decision: deletable\n        when:\n          attribute: state\n          equals: available\n",
+			opaque: true,
+			err:    "rules of service 'ebs' can't replace its checks, since they look at more than the metadata of its resources",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(test.policy), 0o600))
+			viper.Set("policy_file", path)
+
+			mockService := new(MockMetadataChecker)
+			mockService.On("List", mock.Anything).Return(listed, nil)
+			mockService.On("Validate", mock.Anything, "vol-1").Return(true, nil).Maybe()
+			mockService.On("Validate", mock.Anything, "vol-2").Return(true, nil).Maybe()
+			mockService.On("Validate", mock.Anything, "vol-3").Return(false, nil).Maybe()
+			services := []providers.Service{{Name: "ebs", Cleanable: mockService}, {Name: "eni", Cleanable: mockService}}
+			// Like LBs, whose checks look at their listeners, which aren't part of their metadata
+			if test.opaque {
+				services[0].Cleanable = &mockService.MockCleanable
+			}
+
+			err := withPolicy(services, now)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, &policyService{}, services[0].Cleanable)
+			assert.Equal(t, mockService, services[1].Cleanable)
+
+			sum, err := validate(context.Background(), services[0], services[0].String())
+			require.NoError(t, err)
+			decisions := make([]resource.Decision, len(sum.results))
+			for i, r := range sum.results {
+				decisions[i] = r.Decision
+			}
+			assert.Equal(t, test.expected, decisions)
+			mockService.AssertNumberOfCalls(t, "List", 1)
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer viper.Reset()
+	viper.Reset()
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	mockService := new(MockCleanable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{
+		{ID: "res1", Tags: map[string]string{resource.ExpiryTag: "2024-05-09"}},
+		{ID: "res2", Tags: map[string]string{resource.ExpiryTag: "2024-05-10"}},
+		{ID: "res3", Tags: map[string]string{resource.ExpiryTag: "next friday"}},
+		{ID: "res4", Tags: map[string]string{resource.ExpiryTag: "2024-05-09", "cleanup-ignore": "true"}},
+		{ID: "res5"},
+		{ID: "res6", Tags: map[string]string{resource.ExpiryTag: "2024-05-09"}},
+	}, nil)
+	mockService.On("Validate", mock.Anything, "res1").Return(false, nil)
+	mockService.On("Validate", mock.Anything, "res2").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "res3").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "res4").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "res5").Return(false, nil)
+	mockService.On("Validate", mock.Anything, "res6").Return(resource.NewVerdict(resource.Reason{Rule: "found", Decision: resource.Unknown, Message: "resource was not found"}), nil)
+	services := []providers.Service{{Name: "TestService", Cleanable: mockService}}
+	require.NoError(t, withProtection(services, now))
+
+	sum, err := validate(context.Background(), services[0], services[0].String())
+
+	// Expired resources can be deleted even though they're in use, but not when the service can't tell, and leased ones
+	// are kept until they expire. Every resource is still validated, so the reasons of the service are shown along with
+	// the expiry and protection ones
+	assert.NoError(t, err)
+	decisions, messages := make([]resource.Decision, len(sum.results)), make([]string, len(sum.results))
+	for i, r := range sum.results {
+		decisions[i] = r.Decision
+		messages[i] = r.Reasons[len(r.Reasons)-1].Message
+	}
+	assert.Equal(t, []resource.Decision{resource.Deletable, resource.Keep, resource.Unknown, resource.Keep, resource.Keep, resource.Unknown}, decisions)
+	assert.Equal(t, "resource expired at 2024-05-10T00:00:00Z", messages[0])
+	assert.Equal(t, "resource is not empty", sum.results[0].Reasons[0].Message)
+	assert.Equal(t, "resource is leased until 2024-05-11T00:00:00Z", messages[1])
+	assert.Equal(t, "resource has the cleanup-ignore=true protection tag", messages[3])
+	assert.Equal(t, "resource is empty", sum.results[3].Reasons[0].Message)
+	assert.Equal(t, "resource was not found", sum.results[5].Reasons[0].Message)
+	mockService.AssertNumberOfCalls(t, "Validate", 6)
+}
+
+func TestExpiryMinAge(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	defer viper.Reset()
+	viper.Reset()
+	viper.Set("services.TestService.min_age", "1h")
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	mockService := new(MockCleanable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{
+		{ID: "res1", CreatedAt: now.Add(-3 * time.Hour), Tags: map[string]string{resource.ExpiryTag: "2024-05-09"}},
+		{ID: "res2", CreatedAt: now.Add(-10 * time.Minute), Tags: map[string]string{resource.ExpiryTag: "2024-05-09"}},
+	}, nil)
+	mockService.On("Validate", mock.Anything, mock.Anything).Return(false, nil)
+	services := []providers.Service{{Name: "TestService", Cleanable: mockService}}
+	require.NoError(t, withMinAge(services, now, nil))
+	require.NoError(t, withProtection(services, now))
+
+	sum, err := validate(context.Background(), services[0], services[0].String())
+
+	// An expired lease overrides the service finding the resources in use, but not the minimum age
+	assert.NoError(t, err)
+	assert.Equal(t, resource.Deletable, sum.results[0].Decision)
+	assert.Equal(t, resource.Keep, sum.results[1].Decision)
+	assert.Equal(t, "resource was created 10m0s ago, less than the minimum age of 1h0m0s", sum.results[1].Reasons[1].Message)
+}
+
+func TestLease(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		tags      map[string]string
+		expiresAt string
+	}{
+		"Resource without a lease": {
+			expiresAt: "2024-05-17T12:00:00Z",
+		},
+		"Lease extended from when it expires": {
+			tags:      map[string]string{resource.ExpiryTag: "2024-05-12T08:00:00Z"},
+			expiresAt: "2024-05-19T08:00:00Z",
+		},
+		"Expired lease extended from now": {
+			tags:      map[string]string{resource.ExpiryTag: "2024-05-01"},
+			expiresAt: "2024-05-17T12:00:00Z",
+		},
+		"Invalid lease replaced": {
+			tags:      map[string]string{resource.ExpiryTag: "next friday"},
+			expiresAt: "2024-05-17T12:00:00Z",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockService := new(MockTaggable)
+			mockService.On("List", mock.Anything).Return([]resource.Resource{
+				{ID: "res1", ARN: "arn:res1", Tags: test.tags},
+				{ID: "res2"},
+			}, nil)
+			mockService.On("Tag", mock.Anything, "res1", resource.ExpiryTag, test.expiresAt).Return(nil).Once()
+
+			expiresAt, found, err := lease(context.Background(), mockService, "TestService", "arn:res1", 7*24*time.Hour, now)
+
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, test.expiresAt, expiresAt.Format(time.RFC3339))
+			mockService.AssertExpectations(t)
+		})
+	}
+
+	// Resources that don't belong to the service aren't leased, and services that can't tag resources can't lease them
+	mockService := new(MockTaggable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{{ID: "res1"}}, nil)
+	_, found, err := lease(context.Background(), mockService, "TestService", "res9", time.Hour, now)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	mockService.AssertNotCalled(t, "Tag", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	_, _, err = lease(context.Background(), new(MockCleanable), "TestService", "res1", time.Hour, now)
+	assert.EqualError(t, err, "service 'TestService' doesn't support leasing resources")
+}
+
+func TestLimitsFor(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("services.ebs.max_deletions", 10)
+	viper.Set("services.ebs.max_delete_ratio", 0.5)
+	viper.Set("services.eni.max_delete_ratio", 2)
+
+	cases := map[string]struct {
+		service  string
+		flags    []string
+		expected deletionLimits
+		err      string
+	}{
+		"Defaults from the config file": {
+			service:  "ebs",
+			expected: deletionLimits{maxDeletions: 10, maxRatio: 0.5},
+		},
+		"Flags take precedence over the config file": {
+			service:  "ebs",
+			flags:    []string{"--max-deletions", "3"},
+			expected: deletionLimits{maxDeletions: 3, maxRatio: 0.5},
+		},
+		"No limits by default": {
+			service:  "eip",
+			expected: deletionLimits{},
+		},
+		"Invalid ratio": {
+			service: "eni",
+			err:     "maximum ratio of deletions for service 'eni' must be between 0 and 1, got: 2",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.Flags().IntVar(&maxDeletions, "max-deletions", 0, "")
+			cmd.Flags().Float64Var(&maxDeleteRatio, "max-delete-ratio", 0, "")
+			require.NoError(t, cmd.Flags().Parse(test.flags))
+
+			limits, err := limitsFor(cmd, test.service)
+
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, limits)
+		})
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	logger.InitializeLogger("info", "json", io.Discard)
+
+	mockService := new(MockCleanable)
+	mockService.On("List", mock.Anything).Return([]resource.Resource{{ID: "vol-1", Name: "data"}, {ID: "vol-2"}}, nil)
+	mockService.On("Validate", mock.Anything, "vol-1").Return(true, nil)
+	mockService.On("Validate", mock.Anything, "vol-2").Return(false, errors.New("throttled"))
+
+	keepGoing = true
+	defer func() { keepGoing = false }()
+	services := []providers.Service{{Name: "ebs", Region: "us-east-1", Cleanable: mockService}}
+	rep, err := sweep(context.Background(), services, func(ctx context.Context, service providers.Service) (summary, error) {
+		return validate(ctx, service, service.String())
+	})
+	require.Error(t, err)
+
+	cases := map[string]struct {
+		format   string
+		expected string
+	}{
+		"Text only logs results": {
+			format:   "text",
+			expected: "",
+		},
+		"NDJSON": {
+			format: "ndjson",
+			expected: `{"service":"ebs","region":"us-east-1","id":"vol-1","name":"data","outcome":"deletable","decision":"deletable","reasons":[{"rule":"empty","decision":"deletable","message":"resource is empty"}]}
+{"service":"ebs","region":"us-east-1","id":"vol-2","outcome":"failed","error":"error validating resource 'vol-2' in service 'ebs (us-east-1)': throttled"}
+`,
+		},
+		"CSV": {
+			format: "csv",
+			expected: `service,account,region,id,name,type,age,outcome,decision,reasons,error
+ebs,,us-east-1,vol-1,data,,,deletable,deletable,resource is empty,
+ebs,,us-east-1,vol-2,,,,failed,,,error validating resource 'vol-2' in service 'ebs (us-east-1)': throttled
+`,
+		},
+		"Table": {
+			format: "table",
+			expected: `SERVICE  ACCOUNT  REGION     ID     NAME  AGE  OUTCOME    REASONS
+ebs      -        us-east-1  vol-1  data  -    deletable  resource is empty
+ebs      -        us-east-1  vol-2  -     -    failed     error validating resource 'vol-2' in service 'ebs (us-east-1)': throttled
+`,
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			require.NoError(t, rep.write(&buf, test.format, "validate"))
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, rep.write(&buf, "json", "validate"))
+
+		doc := new(document)
+		require.NoError(t, json.Unmarshal(buf.Bytes(), doc))
+		assert.Equal(t, schemaVersion, doc.SchemaVersion)
+		assert.Equal(t, "validate", doc.Command)
+		assert.Len(t, doc.Results, 2)
+		assert.Equal(t, 1, doc.Summary.Succeeded)
+		assert.Equal(t, 1, doc.Summary.Failed)
+	})
+}
+
+func TestForEach(t *testing.T) {
+	ctx := context.Background()
+
+	cases := map[string]struct {
+		ctx      func() context.Context
+		fn       func(results []int) func(context.Context, int) error
+		testCase func(*testing.T, []int, error)
+	}{
+		"Every index is processed and results keep their order": {
+			ctx: func() context.Context { return ctx },
+			fn: func(results []int) func(context.Context, int) error {
+				return func(ctx context.Context, i int) error {
+					// Later indexes finish first to make sure ordering doesn't depend on completion time
+					time.Sleep(time.Duration(len(results)-i) * time.Millisecond)
+					results[i] = i * 10
+					return nil
+				}
+			},
+			testCase: func(t *testing.T, results []int, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, []int{0, 10, 20, 30, 40, 50, 60, 70}, results)
+			},
+		},
+		"First error is returned and remaining work is cancelled": {
+			ctx: func() context.Context { return ctx },
+			fn: func(results []int) func(context.Context, int) error {
+				return func(ctx context.Context, i int) error {
+					if i == 0 {
+						return errors.New("first error")
+					}
+					<-ctx.Done()
+					return ctx.Err()
+				}
+			},
+			testCase: func(t *testing.T, results []int, err error) {
+				assert.EqualError(t, err, "first error")
+			},
+		},
+		"Cancelled context stops the work from being handed out": {
+			ctx: func() context.Context {
+				cancelled, cancel := context.WithCancel(ctx)
+				cancel()
+				return cancelled
+			},
+			fn: func(results []int) func(context.Context, int) error {
+				return func(ctx context.Context, i int) error {
+					results[i] = 1
+					return nil
+				}
+			},
+			testCase: func(t *testing.T, results []int, err error) {
+				assert.ErrorIs(t, err, context.Canceled)
+				assert.Equal(t, make([]int, len(results)), results)
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			results := make([]int, 8)
+			err := forEach(test.ctx(), 4, len(results), test.fn(results))
+			test.testCase(t, results, err)
+		})
+	}
+}
+
+func TestValidateConcurrently(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+	mockService := new(MockCleanable)
+
+	// Initialize logger and set it to output to a buffer
+	logger.InitializeLogger("info", "json", &buf)
+
+	concurrency = 3
+	defer func() { concurrency = 1 }()
+
+	resources := []string{"res1", "res2", "res3", "res4", "res5"}
+	mockService.On("List", mock.Anything).Return(resources, nil)
+	for i, resource := range resources {
+		mockService.On("Validate", mock.Anything, resource).Return(i%2 == 0, nil)
+	}
+
+	sum, err := validate(ctx, mockService, "TestService")
+	require.NoError(t, err)
+	assert.Equal(t, 3, sum.succeeded)
+
+	// Results must be logged in the same order the resources were listed
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		log := new(LogOutput)
+		require.NoError(t, json.Unmarshal([]byte(line), log))
+		messages = append(messages, log.Msg)
+	}
+	assert.Equal(t, []string{
+		"Validating resources for service: TestService",
+		"Resource 'res1' in service 'TestService' is empty and can be excluded.",
+		"Resource 'res2' in service 'TestService' is not empty and cannot be excluded.",
+		"Resource 'res3' in service 'TestService' is empty and can be excluded.",
+		"Resource 'res4' in service 'TestService' is not empty and cannot be excluded.",
+		"Resource 'res5' in service 'TestService' is empty and can be excluded.",
+	}, messages)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestKeepGoing(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+	firstService, secondService := new(MockCleanable), new(MockCleanable)
+	services := []providers.Service{
+		{Name: "FirstService", Cleanable: firstService},
+		{Name: "SecondService", Cleanable: secondService},
+	}
+
+	// Initialize logger and set it to output to a buffer
+	logger.InitializeLogger("info", "json", &buf)
+
+	// Test cases
+	cases := map[string]struct {
+		keepGoing bool
+		run       func(context.Context, providers.Service) (summary, error)
+		helpers   func()
+		testCase  func(*testing.T, report, error)
+	}{
+		"Validation failures don't stop the remaining resources and services from being validated": {
+			keepGoing: true,
+			run: func(ctx context.Context, service providers.Service) (summary, error) {
+				return validate(ctx, service, service.Name)
+			},
+			helpers: func() {
+				firstService.On("List", mock.Anything).Return([]string{"res1", "res2", "res3"}, nil)
+				firstService.On("Validate", mock.Anything, "res1").Return(false, errors.New("validation error"))
+				firstService.On("Validate", mock.Anything, "res2").Return(true, nil)
+				firstService.On("Validate", mock.Anything, "res3").Return(false, nil)
+				secondService.On("List", mock.Anything).Return([]string{"res4"}, nil)
+				secondService.On("Validate", mock.Anything, "res4").Return(true, nil)
+			},
+			testCase: func(t *testing.T, rep report, err error) {
+				require.Len(t, rep, 2)
+				assert.Equal(t, 1, rep[0].succeeded)
+				assert.Equal(t, 1, rep[0].skipped)
+				assert.Len(t, rep[0].failures, 1)
+				assert.Equal(t, 1, rep[1].succeeded)
+				assert.EqualError(t, err, "error validating resource 'res1' in service 'FirstService': validation error")
+			},
+		},
+		"Deletion and listing failures are joined after every service is processed": {
+			keepGoing: true,
+			run: func(ctx context.Context, service providers.Service) (summary, error) {
+				return delete(ctx, service, service.Name, deletionLimits{})
+			},
+			helpers: func() {
+				firstService.On("List", mock.Anything).Return([]string{"res1", "res2", "res3", "res4"}, nil)
+				firstService.On("Validate", mock.Anything, "res1").Return(true, nil)
+				firstService.On("Validate", mock.Anything, "res2").Return(true, nil)
+				firstService.On("Validate", mock.Anything, "res3").Return(false, errors.New("validation error"))
+				firstService.On("Validate", mock.Anything, "res4").Return(false, nil)
+				firstService.On("Delete", mock.Anything, "res1").Return(errors.New("delete error"))
+				firstService.On("Delete", mock.Anything, "res2").Return(nil)
+				secondService.On("List", mock.Anything).Return([]string{}, errors.New("list error"))
+			},
+			testCase: func(t *testing.T, rep report, err error) {
+				total := rep.total()
+				assert.Equal(t, 1, total.succeeded)
+				assert.Equal(t, 1, total.skipped)
+				assert.Len(t, total.failures, 3)
+				assert.EqualError(t, err, "error deleting resource 'res1' in service 'FirstService': delete error\n"+
+					"error validating resource 'res3' in service 'FirstService': validation error\n"+
+					"error listing resources for service 'SecondService': list error")
+
+				log, testErr := getLastLogLine(buf.String())
+				require.NoError(t, testErr)
+				assert.Equal(t, "Summary for all services: 1 succeeded, 1 skipped, 3 failed", log)
+			},
+		},
+		"Without keep-going the first failing service stops the remaining ones": {
+			run: func(ctx context.Context, service providers.Service) (summary, error) {
+				return validate(ctx, service, service.Name)
+			},
+			helpers: func() {
+				firstService.On("List", mock.Anything).Return([]string{}, errors.New("list error"))
+			},
+			testCase: func(t *testing.T, rep report, err error) {
+				assert.Len(t, rep, 1)
+				assert.EqualError(t, err, "error listing resources for service 'FirstService': list error")
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			firstService.ExpectedCalls, secondService.ExpectedCalls = nil, nil
+			keepGoing = test.keepGoing
+			defer func() { keepGoing = false }()
+
+			test.helpers()
+
+			rep, err := sweep(ctx, services, test.run)
+			rep.log(ctx)
+
+			test.testCase(t, rep, err)
+
+			buf.Reset()
+			firstService.AssertExpectations(t)
+			secondService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	cases := map[string]struct {
+		input  error
+		expect int
+	}{
+		"No error":                     {input: nil, expect: ExitOK},
+		"Error without an exit code":   {input: errors.New("unknown command"), expect: ExitFatal},
+		"Fatal error":                  {input: withExitCode(ExitFatal, errors.New("provider error")), expect: ExitFatal},
+		"Partial failure":              {input: withExitCode(ExitFailure, errors.Join(errors.New("a"), errors.New("b"))), expect: ExitFailure},
+		"Findings":                     {input: withExitCode(ExitFindings, errors.New("1 resource(s) can be excluded")), expect: ExitFindings},
+		"Wrapped error keeps its code": {input: fmt.Errorf("wrapped: %w", withExitCode(ExitFailure, errors.New("delete error"))), expect: ExitFailure},
+		"Nil error with an exit code":  {input: withExitCode(ExitFailure, nil), expect: ExitOK},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expect, ExitCode(test.input))
+		})
+	}
+}
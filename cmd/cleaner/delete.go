@@ -6,39 +6,179 @@ import (
 
 	"github.com/loureirovinicius/cleanup/helpers/logger"
 	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 )
 
-// Delete unused instances of the service passed as parameter
-func delete(ctx context.Context, service providers.Cleanable, serviceName string) error {
+// Outcome of validating and, when it's empty, deleting a single resource
+type deletion struct {
+	validated bool
+	verdict   resource.Verdict
+	deleted   bool
+	err       error
+}
+
+// Delete unused instances of the service passed as parameter. Nothing is deleted when there are more unused resources
+// than the limits allow
+func delete(ctx context.Context, service providers.Cleanable, serviceName string, limits deletionLimits) (summary, error) {
 	logger.Log(ctx, "info", fmt.Sprintf("Deleting resources for service: %s", serviceName))
 
-	// List all resources for the given service
-	resources, err := service.List(ctx)
+	// List the resources of the given service matching the filter, if any
+	resources, err := listFiltered(ctx, service, serviceName)
 	if err != nil {
-		return fmt.Errorf("error listing resources for service '%s': %w", serviceName, err)
+		return summary{}, err
 	}
+	listed := len(resources)
+	// Resources that weren't targeted are left untouched, as if they weren't listed
+	resources = targets.filter(resources)
 
-	// Iterate through each resource to validate and delete if empty
-	for _, resource := range resources {
-		empty, err := service.Validate(ctx, resource)
-		if err != nil {
-			return fmt.Errorf("error validating resource '%v' in service '%s': %w", resource, serviceName, err)
+	// Validate resources concurrently, keeping the results in the same order as the resources
+	validations, err := validateAll(ctx, service, serviceName, resources)
+	results := make([]deletion, len(resources))
+	var candidates []int
+	for i, result := range validations {
+		results[i] = deletion{validated: result.validated, verdict: result.verdict, err: result.err}
+		if result.validated && result.verdict.Deletable() {
+			candidates = append(candidates, i)
 		}
+	}
 
-		if empty {
-			logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is empty and can be excluded.", resource, serviceName))
+	// The limits are enforced before anything is deleted, so an unexpected number of unused resources (like after a
+	// misconfiguration) aborts the whole service instead of deleting part of it
+	if err == nil {
+		err = limits.check(serviceName, len(candidates), listed)
+	}
 
-			// Attempt to delete the empty resource
-			err = service.Delete(ctx, resource)
-			if err != nil {
-				return fmt.Errorf("error deleting resource '%v' in service '%s': %w", resource, serviceName, err)
-			}
-			logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' has been deleted successfully.", resource, serviceName))
-		} else {
-			logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is not empty and cannot be excluded.", resource, serviceName))
+	// When running interactively, only the resources the operator confirmed are deleted
+	if err == nil {
+		candidates, err = picker.pick(serviceName, resources, results, candidates)
+	}
+
+	// Empty resources are validated again against their current state right before being deleted, unless the service
+	// already described them while validating
+	var refreshed bool
+	if err == nil {
+		ids := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			ids[i] = resources[candidate].ID
 		}
+		refreshed, err = refresh(ctx, service, serviceName, ids)
+	}
+	if err == nil {
+		err = forEach(ctx, concurrency, len(candidates), func(ctx context.Context, i int) error {
+			resource, result := resources[candidates[i]].ID, &results[candidates[i]]
+			if refreshed {
+				return validateAndDelete(ctx, service, serviceName, resource, result)
+			}
+			return deleteResource(ctx, service, serviceName, resource, result)
+		})
+	}
+
+	sum := reportDeletions(ctx, serviceName, resources, results)
+	if err != nil {
+		return sum, err
 	}
 
 	logger.Log(ctx, "debug", fmt.Sprintf("Deletion completed for service: %s", serviceName))
+	return sum, sum.err()
+}
+
+// Refresh the metadata of the resources passed as parameter when the service validates them against what was listed.
+// Returns whether they were refreshed
+func refresh(ctx context.Context, service providers.Cleanable, serviceName string, resources []string) (bool, error) {
+	refresher, ok := unwrap(service).(providers.Refresher)
+	if !ok || len(resources) == 0 {
+		return false, nil
+	}
+
+	if err := refresher.Refresh(ctx, resources); err != nil {
+		return false, fmt.Errorf("error refreshing resources for service '%s': %w", serviceName, err)
+	}
+	return true, nil
+}
+
+// Implemented by services wrapping another one to change how its resources are validated
+type wrapper interface {
+	Unwrap() providers.Cleanable
+}
+
+// Implemented by wrappers adding reasons of their own to the verdicts of the service they wrap
+type ruleWrapper interface {
+	wrapper
+	rules() []string
+}
+
+// Actual implementation of a service. Services are usually wrapped with where they were loaded, and sometimes with
+// policies, which hides the methods of the implementation
+func unwrap(service providers.Cleanable) providers.Cleanable {
+	for {
+		switch wrapped := service.(type) {
+		case providers.Service:
+			service = wrapped.Cleanable
+		case wrapper:
+			service = wrapped.Unwrap()
+		default:
+			return service
+		}
+	}
+}
+
+// Validate a single resource and delete it if it's empty, recording what was done in the result
+func validateAndDelete(ctx context.Context, service providers.Cleanable, serviceName string, resource string, result *deletion) error {
+	verdict, err := service.Validate(ctx, resource)
+	if err != nil {
+		result.err = fmt.Errorf("error validating resource '%v' in service '%s': %w", resource, serviceName, err)
+		return halt(result.err)
+	}
+	result.validated, result.verdict = true, verdict
+
+	if !verdict.Deletable() {
+		return nil
+	}
+
+	return deleteResource(ctx, service, serviceName, resource, result)
+}
+
+// Delete a single resource that was found to be empty, recording the outcome in the result
+func deleteResource(ctx context.Context, service providers.Cleanable, serviceName string, resource string, result *deletion) error {
+	err := service.Delete(ctx, resource)
+	if err != nil {
+		result.err = fmt.Errorf("error deleting resource '%v' in service '%s': %w", resource, serviceName, err)
+		return halt(result.err)
+	}
+	result.deleted = true
+
 	return nil
 }
+
+// Log what happened to each resource, in the same order they were listed
+func reportDeletions(ctx context.Context, serviceName string, resources []resource.Resource, results []deletion) summary {
+	var sum summary
+	for i, resource := range resources {
+		result := results[i]
+		sum.add(result.deleted, result.err)
+		sum.record(deletionResult(resource, result))
+		if !result.validated {
+			continue
+		}
+
+		logVerdict(ctx, resource, serviceName, result.verdict)
+		if result.deleted {
+			logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' has been deleted successfully.", resource, serviceName))
+		}
+	}
+
+	return sum
+}
+
+// Outcome of a resource that may have been deleted
+func deletionResult(res resource.Resource, result deletion) result {
+	switch {
+	case result.deleted:
+		return newResult(res, outcomeDeleted, result.verdict, nil)
+	case result.err != nil && result.validated:
+		return newResult(res, outcomeFailed, result.verdict, result.err)
+	case !result.validated:
+		return failedResult(res, result.err)
+	}
+	return verdictResult(res, result.verdict)
+}
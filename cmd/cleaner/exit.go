@@ -0,0 +1,52 @@
+package cleaner
+
+import (
+	"errors"
+)
+
+// Process exit codes, so pipelines can tell apart what happened during the execution
+const (
+	// Execution succeeded and there's nothing to clean
+	ExitOK = 0
+	// Configuration or provider errors prevented the execution from happening
+	ExitFatal = 1
+	// Some resources couldn't be listed, validated or deleted
+	ExitFailure = 2
+	// Execution succeeded and validation found resources that can be deleted
+	ExitFindings = 3
+)
+
+// Error carrying the exit code the process should finish with
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// Attach an exit code to the error. Nil errors stay nil
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// Exit code matching the error returned by Run. Errors without an exit code are considered fatal
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return ExitFatal
+}
@@ -0,0 +1,106 @@
+package cleaner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Why a single resource can or cannot be deleted
+type explanation struct {
+	Service  string            `json:"service"`
+	Resource resource.Resource `json:"resource"`
+	Verdict  resource.Verdict  `json:"verdict"`
+}
+
+// Find the resource passed as parameter, by its ID or ARN, and evaluate every rule of the service against it.
+// A nil explanation is returned when the resource doesn't belong to the service
+func explain(ctx context.Context, service providers.Cleanable, serviceName string, id string) (*explanation, error) {
+	logger.Log(ctx, "debug", fmt.Sprintf("Looking for resource '%s' in service: %s", id, serviceName))
+
+	res, found, err := find(ctx, service, serviceName, id)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	verdict, err := service.Validate(ctx, res.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error validating resource '%v' in service '%s': %w", res.ID, serviceName, err)
+	}
+
+	return &explanation{Service: serviceName, Resource: res, Verdict: verdict}, nil
+}
+
+// Find a resource of the service by its ID or ARN
+func find(ctx context.Context, service providers.Cleanable, serviceName string, id string) (resource.Resource, bool, error) {
+	resources, err := service.List(ctx)
+	if err != nil {
+		return resource.Resource{}, false, fmt.Errorf("error listing resources for service '%s': %w", serviceName, err)
+	}
+
+	i := slices.IndexFunc(resources, func(res resource.Resource) bool {
+		return res.ID == id || (res.ARN != "" && res.ARN == id)
+	})
+	if i < 0 {
+		return resource.Resource{}, false, nil
+	}
+	return resources[i], true, nil
+}
+
+// Write the explanation either as JSON or as human-readable text
+func (e *explanation) write(w io.Writer, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(e)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Resource:   %s\n", e.Resource)
+	fmt.Fprintf(&b, "Service:    %s\n", e.Service)
+	if e.Resource.Type != "" {
+		fmt.Fprintf(&b, "Type:       %s\n", e.Resource.Type)
+	}
+	if !e.Resource.CreatedAt.IsZero() {
+		fmt.Fprintf(&b, "Created at: %s\n", e.Resource.CreatedAt.Format(time.RFC3339))
+	}
+	if len(e.Resource.Tags) > 0 {
+		fmt.Fprintf(&b, "Tags:       %s\n", formatPairs(e.Resource.Tags, ", "))
+	}
+	if len(e.Resource.Attributes) > 0 {
+		fmt.Fprintf(&b, "Attributes: %s\n", formatPairs(e.Resource.Attributes, ", "))
+	}
+
+	fmt.Fprintf(&b, "Decision:   %s\n", e.Verdict.Decision)
+	fmt.Fprintln(&b, "Rules:")
+	for _, reason := range e.Verdict.Reasons {
+		fmt.Fprintf(&b, "  - %s: %s (%s)\n", reason.Rule, reason.Decision, reason.Message)
+		if len(reason.Evidence) > 0 {
+			fmt.Fprintf(&b, "    looked at: %s\n", formatPairs(reason.Evidence, ", "))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Key-value pairs sorted by key, like 'a=1, b=2'
+func formatPairs(pairs map[string]string, sep string) string {
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	formatted := make([]string, len(keys))
+	for i, key := range keys {
+		formatted[i] = fmt.Sprintf("%s=%s", key, pairs[key])
+	}
+	return strings.Join(formatted, sep)
+}
@@ -0,0 +1,51 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/helpers/filter"
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Parse the '--filter' flag. There's no expression when it wasn't set
+func loadFilter(raw string) (*filter.Expr, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	expr, err := filter.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter '%s': %w", raw, err)
+	}
+	return expr, nil
+}
+
+// List the resources of the service matching the filter expression, if any. The equalities the expression requires are
+// pushed down to services that can filter what they list, and every resource listed is matched against it anyway
+func listFiltered(ctx context.Context, service providers.Cleanable, serviceName string) ([]resource.Resource, error) {
+	if filterExpr != nil {
+		if filterer, ok := unwrap(service).(providers.Filterer); ok {
+			filterer.Filter(filterExpr.Pushdown())
+		}
+	}
+
+	resources, err := service.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing resources for service '%s': %w", serviceName, err)
+	}
+	if filterExpr == nil {
+		return resources, nil
+	}
+
+	now := time.Now()
+	var matched []resource.Resource
+	for _, res := range resources {
+		if filterExpr.Match(res, now) {
+			matched = append(matched, res)
+		}
+	}
+	return matched, nil
+}
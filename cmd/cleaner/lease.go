@@ -0,0 +1,42 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Extend the lease of the resource passed as parameter, by its ID or ARN, tagging it with the time it expires at. Leases
+// that didn't expire yet are extended from when they expire, and the other ones from now. Returns when the resource
+// expires, and whether it belongs to the service at all
+func lease(ctx context.Context, service providers.Cleanable, serviceName string, id string, extend time.Duration, now time.Time) (time.Time, bool, error) {
+	tagger, ok := unwrap(service).(providers.Tagger)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("service '%s' doesn't support leasing resources", serviceName)
+	}
+
+	res, found, err := find(ctx, service, serviceName, id)
+	if err != nil || !found {
+		return time.Time{}, false, err
+	}
+
+	from := now
+	if value, ok := res.Tags[resource.ExpiryTag]; ok {
+		// Invalid expiry tags are simply replaced
+		if expiresAt, err := resource.ParseExpiry(value); err == nil && expiresAt.After(now) {
+			from = expiresAt
+		}
+	}
+	expiresAt := from.Add(extend).UTC().Truncate(time.Second)
+
+	if err := tagger.Tag(ctx, res.ID, resource.ExpiryTag, expiresAt.Format(time.RFC3339)); err != nil {
+		return time.Time{}, true, fmt.Errorf("error leasing resource '%v' in service '%s': %w", res, serviceName, err)
+	}
+
+	logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is leased until %s.", res, serviceName, expiresAt.Format(time.RFC3339)))
+	return expiresAt, true, nil
+}
@@ -0,0 +1,48 @@
+package cleaner
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Upper bounds of how many resources of a service a single run is allowed to delete in each account and region. Zero
+// means no limit
+type deletionLimits struct {
+	maxDeletions int
+	maxRatio     float64
+}
+
+// Limits of the service passed as parameter. Flags take precedence over the defaults of the service in the config file
+func limitsFor(cmd *cobra.Command, serviceName string) (deletionLimits, error) {
+	limits := deletionLimits{
+		maxDeletions: viper.GetInt(fmt.Sprintf("services.%s.max_deletions", serviceName)),
+		maxRatio:     viper.GetFloat64(fmt.Sprintf("services.%s.max_delete_ratio", serviceName)),
+	}
+	if cmd.Flags().Changed("max-deletions") {
+		limits.maxDeletions = maxDeletions
+	}
+	if cmd.Flags().Changed("max-delete-ratio") {
+		limits.maxRatio = maxDeleteRatio
+	}
+
+	if limits.maxDeletions < 0 {
+		return limits, fmt.Errorf("maximum number of deletions for service '%s' must not be negative, got: %d", serviceName, limits.maxDeletions)
+	}
+	if limits.maxRatio < 0 || limits.maxRatio > 1 {
+		return limits, fmt.Errorf("maximum ratio of deletions for service '%s' must be between 0 and 1, got: %g", serviceName, limits.maxRatio)
+	}
+	return limits, nil
+}
+
+// Check whether deleting the candidates out of all the listed resources stays within the limits
+func (l deletionLimits) check(serviceName string, candidates, listed int) error {
+	if l.maxDeletions > 0 && candidates > l.maxDeletions {
+		return fmt.Errorf("%d resource(s) of service '%s' would be deleted, more than the maximum of %d: no resource was deleted", candidates, serviceName, l.maxDeletions)
+	}
+	if l.maxRatio > 0 && listed > 0 && float64(candidates)/float64(listed) > l.maxRatio {
+		return fmt.Errorf("%d out of %d resource(s) of service '%s' would be deleted, more than the maximum ratio of %g: no resource was deleted", candidates, listed, serviceName, l.maxRatio)
+	}
+	return nil
+}
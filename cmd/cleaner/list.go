@@ -7,21 +7,30 @@ import (
 
 	"github.com/loureirovinicius/cleanup/helpers/logger"
 	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 )
 
-// List instances of the service passed as parameter
-func list(ctx context.Context, service providers.Cleanable, serviceName string) error {
+// List instances of the service passed as parameter. Every resource listed is counted as succeeded
+func list(ctx context.Context, service providers.Cleanable, serviceName string) (summary, error) {
+	var sum summary
+
 	// List all created resources for a service
 	logger.Log(ctx, "info", fmt.Sprintf("Listing resources for service: %s", serviceName))
 
-	resources, err := service.List(ctx)
+	resources, err := listFiltered(ctx, service, serviceName)
 	if err != nil {
-		return fmt.Errorf("error listing resources for service '%s': %w", serviceName, err)
+		return sum, err
 	}
 
-	// Join resource IDs or names into a single string for logging
-	resourceList := strings.Join(resources, ", ")
+	// Join resource IDs and names into a single string for logging
+	names := make([]string, len(resources))
+	for i, res := range resources {
+		names[i] = res.String()
+		sum.add(true, nil)
+		sum.record(newResult(res, outcomeListed, resource.Verdict{}, nil))
+	}
+	resourceList := strings.Join(names, ", ")
 	logger.Log(ctx, "info", fmt.Sprintf("Resources for %s: %s", serviceName, resourceList))
 
-	return nil
+	return sum, nil
 }
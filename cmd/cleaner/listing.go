@@ -0,0 +1,52 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Resources found by the last List call of a service, for wrappers deciding about resources by their metadata
+type listing struct {
+	mu     sync.Mutex
+	listed map[string]resource.Resource
+}
+
+// Keep the resources passed as parameter, forgetting the ones listed before
+func (l *listing) store(resources []resource.Resource) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.storeLocked(resources)
+}
+
+func (l *listing) storeLocked(resources []resource.Resource) {
+	l.listed = make(map[string]resource.Resource, len(resources))
+	for _, res := range resources {
+		l.listed[res.ID] = res
+	}
+}
+
+// Resource with the ID passed as parameter, and whether the service has it. Resources validated without being listed
+// first (like the ones in a plan) are looked up by listing the service once, so changes made in the meantime are seen.
+// Resources that aren't found are only known by their ID
+func (l *listing) lookup(ctx context.Context, service providers.Cleanable, id string) (resource.Resource, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.listed == nil {
+		resources, err := service.List(ctx)
+		if err != nil {
+			return resource.Resource{}, false, fmt.Errorf("error listing resources to look up their metadata: %w", err)
+		}
+		l.storeLocked(resources)
+	}
+
+	res, ok := l.listed[id]
+	if !ok {
+		return resource.Resource{ID: id}, false, nil
+	}
+	return res, true, nil
+}
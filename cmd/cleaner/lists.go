@@ -0,0 +1,132 @@
+package cleaner
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/spf13/viper"
+)
+
+// Pattern matching resources by their ID, ARN or name. Patterns between slashes (like /^dr-.*$/) are regular
+// expressions, and the other ones are globs (like vol-* or *-backup), matching the whole value. Globs without
+// wildcards match exact IDs and ARNs
+type resourcePattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+func parsePattern(raw string) (resourcePattern, error) {
+	if raw == "" {
+		return resourcePattern{}, errors.New("patterns must not be empty")
+	}
+	if len(raw) > 1 && strings.HasPrefix(raw, "/") && strings.HasSuffix(raw, "/") {
+		re, err := regexp.Compile(raw[1 : len(raw)-1])
+		if err != nil {
+			return resourcePattern{}, fmt.Errorf("invalid regular expression '%s': %w", raw, err)
+		}
+		return resourcePattern{raw: raw, re: re}, nil
+	}
+
+	// Wildcards of globs match any character, including the slashes of ARNs
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range raw {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return resourcePattern{raw: raw, re: regexp.MustCompile(b.String())}, nil
+}
+
+func (p resourcePattern) String() string {
+	return p.raw
+}
+
+func (p resourcePattern) match(res resource.Resource) bool {
+	for _, value := range []string{res.ID, res.ARN, res.Name} {
+		if value != "" && p.re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resources of a service that are always skipped (exclude) and the ones always considered (include), even when they
+// match an exclude pattern. Including resources doesn't skip the other ones: a service is scoped to the included
+// resources by excluding every other one (like with the * pattern)
+type resourceLists struct {
+	exclude []resourcePattern
+	include []resourcePattern
+}
+
+// Reason keeping a resource that's excluded without being included, if any
+func (l resourceLists) reason(res resource.Resource) (resource.Reason, bool) {
+	for _, p := range l.include {
+		if p.match(res) {
+			return resource.Reason{}, false
+		}
+	}
+
+	for _, p := range l.exclude {
+		if p.match(res) {
+			return resource.Reason{
+				Rule:     "exclude-list",
+				Decision: resource.Keep,
+				Message:  fmt.Sprintf("resource matches the excluded pattern %s", p),
+				Evidence: map[string]string{"pattern": p.String()},
+			}, true
+		}
+	}
+	return resource.Reason{}, false
+}
+
+// Load the file with the resource lists of the services ('resource_lists_file'), if any. It has the same 'services'
+// section as the config file, so lists can be kept apart from it
+func loadListsFile() (*viper.Viper, error) {
+	path := viper.GetString("resource_lists_file")
+	if path == "" {
+		return nil, nil
+	}
+
+	file := viper.New()
+	file.SetConfigFile(path)
+	file.SetConfigType("yaml")
+	if err := file.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading resource lists file: %w", err)
+	}
+	return file, nil
+}
+
+// Resource lists of the service passed as parameter, merging the ones of the config file with the ones of the resource
+// lists file, if any
+func resourceListsFor(serviceName string, file *viper.Viper) (resourceLists, error) {
+	var lists resourceLists
+	for _, list := range []struct {
+		name     string
+		patterns *[]resourcePattern
+	}{{"exclude", &lists.exclude}, {"include", &lists.include}} {
+		key := fmt.Sprintf("services.%s.%s", serviceName, list.name)
+		entries := viper.GetStringSlice(key)
+		if file != nil {
+			entries = append(entries, file.GetStringSlice(key)...)
+		}
+
+		for _, entry := range entries {
+			p, err := parsePattern(entry)
+			if err != nil {
+				return lists, fmt.Errorf("invalid %s pattern of service '%s': %w", list.name, serviceName, err)
+			}
+			*list.patterns = append(*list.patterns, p)
+		}
+	}
+	return lists, nil
+}
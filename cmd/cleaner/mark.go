@@ -0,0 +1,214 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/helpers/duration"
+	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Outcome of validating a single resource and marking or unmarking it
+type marking struct {
+	validated bool
+	verdict   resource.Verdict
+	changed   bool
+	err       error
+}
+
+// Mark the unused instances of the service passed as parameter with the time they were found unused, and remove the
+// mark of the ones that are in use again. Resources that were already marked keep their original mark
+func mark(ctx context.Context, service providers.Cleanable, serviceName string, now time.Time) (summary, error) {
+	logger.Log(ctx, "info", fmt.Sprintf("Marking resources for service: %s", serviceName))
+
+	tagger, ok := unwrap(service).(providers.Tagger)
+	if !ok {
+		return summary{}, fmt.Errorf("service '%s' doesn't support marking resources", serviceName)
+	}
+
+	// List all resources for the given service
+	resources, err := service.List(ctx)
+	if err != nil {
+		return summary{}, fmt.Errorf("error listing resources for service '%s': %w", serviceName, err)
+	}
+
+	// Validate resources concurrently, keeping the results in the same order as the resources
+	validations, err := validateAll(ctx, service, serviceName, resources)
+	results := make([]marking, len(resources))
+	for i, result := range validations {
+		results[i] = marking{validated: result.validated, verdict: result.verdict, err: result.err}
+	}
+	if err == nil {
+		err = forEach(ctx, concurrency, len(resources), func(ctx context.Context, i int) error {
+			return markResource(ctx, tagger, serviceName, resources[i], &results[i], now)
+		})
+	}
+
+	var sum summary
+	for i, res := range resources {
+		result := results[i]
+		sum.add(result.changed, result.err)
+		sum.record(markingResult(res, result))
+	}
+
+	if err != nil {
+		return sum, err
+	}
+
+	logger.Log(ctx, "debug", fmt.Sprintf("Marking completed for service: %s", serviceName))
+	return sum, sum.err()
+}
+
+// Mark a single resource when it's unused, or unmark it when it's in use again, recording what was done in the result
+func markResource(ctx context.Context, tagger providers.Tagger, serviceName string, res resource.Resource, result *marking, now time.Time) error {
+	if !result.validated {
+		return nil
+	}
+
+	markedAt, marked := res.MarkedAt()
+	switch {
+	case result.verdict.Deletable() && marked:
+		logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' was already marked as unused at %s.", res, serviceName, markedAt.Format(time.RFC3339)))
+	case result.verdict.Deletable():
+		if err := tagger.Tag(ctx, res.ID, resource.MarkTag, now.UTC().Format(time.RFC3339)); err != nil {
+			result.err = fmt.Errorf("error marking resource '%v' in service '%s': %w", res, serviceName, err)
+			return halt(result.err)
+		}
+		result.changed = true
+		logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' has been marked as unused.", res, serviceName), verdictAttrs(result.verdict)...)
+	case result.verdict.Decision == resource.Keep && marked:
+		if err := tagger.Untag(ctx, res.ID, resource.MarkTag); err != nil {
+			result.err = fmt.Errorf("error unmarking resource '%v' in service '%s': %w", res, serviceName, err)
+			return halt(result.err)
+		}
+		result.changed = true
+		logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is in use again and has been unmarked.", res, serviceName), verdictAttrs(result.verdict)...)
+	}
+
+	return nil
+}
+
+// Outcome of a resource that may have been marked or unmarked
+func markingResult(res resource.Resource, result marking) result {
+	_, marked := res.MarkedAt()
+	switch {
+	case result.err != nil && result.validated:
+		return newResult(res, outcomeFailed, result.verdict, result.err)
+	case !result.validated:
+		return failedResult(res, result.err)
+	case result.verdict.Deletable():
+		return newResult(res, outcomeMarked, result.verdict, nil)
+	case result.verdict.Decision == resource.Keep && marked:
+		return newResult(res, outcomeUnmarked, result.verdict, nil)
+	}
+	return verdictResult(res, result.verdict)
+}
+
+// Service whose resources are only deletable once they were marked as unused longer than the grace period ago. The
+// mark of resources that are in use again is removed while validating them
+type markedService struct {
+	providers.Cleanable
+	tagger providers.Tagger
+	grace  time.Duration
+	now    time.Time
+
+	// When each resource was marked, as found by the last List call
+	marks map[string]time.Time
+}
+
+// Wrap the service passed as parameter so only resources marked longer than the grace period ago are deleted
+func withGracePeriod(service providers.Cleanable, serviceName string, grace time.Duration, now time.Time) (*markedService, error) {
+	tagger, ok := unwrap(service).(providers.Tagger)
+	if !ok {
+		return nil, fmt.Errorf("service '%s' doesn't support marking resources", serviceName)
+	}
+	return &markedService{Cleanable: service, tagger: tagger, grace: grace, now: now}, nil
+}
+
+func (s *markedService) List(ctx context.Context) ([]resource.Resource, error) {
+	resources, err := s.Cleanable.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.marks = map[string]time.Time{}
+	for _, res := range resources {
+		if markedAt, marked := res.MarkedAt(); marked {
+			s.marks[res.ID] = markedAt
+		}
+	}
+	return resources, nil
+}
+
+// Resources are always validated again before being swept. Services that can't refresh them describe them again anyway
+func (s *markedService) Refresh(ctx context.Context, ids []string) error {
+	if refresher, ok := unwrap(s.Cleanable).(providers.Refresher); ok {
+		return refresher.Refresh(ctx, ids)
+	}
+	return nil
+}
+
+func (s *markedService) Validate(ctx context.Context, id string) (resource.Verdict, error) {
+	verdict, err := s.Cleanable.Validate(ctx, id)
+	if err != nil {
+		return verdict, err
+	}
+
+	markedAt, marked := s.marks[id]
+	switch {
+	case verdict.Deletable():
+		return resource.NewVerdict(append(slices.Clone(verdict.Reasons), s.graceReason(markedAt, marked))...), nil
+	case verdict.Decision == resource.Keep && marked:
+		if err := s.tagger.Untag(ctx, id, resource.MarkTag); err != nil {
+			return resource.Verdict{}, fmt.Errorf("error removing the mark: %w", err)
+		}
+		reason := resource.Reason{Rule: "mark", Decision: resource.Keep, Message: "resource is in use again, so its mark was removed", Evidence: map[string]string{"marked_at": markedAt.Format(time.RFC3339)}}
+		return resource.NewVerdict(append(slices.Clone(verdict.Reasons), reason)...), nil
+	}
+	return verdict, nil
+}
+
+// Reason telling whether an unused resource was marked longer than the grace period ago
+func (s *markedService) graceReason(markedAt time.Time, marked bool) resource.Reason {
+	reason := resource.Reason{Rule: "grace-period", Decision: resource.Keep, Evidence: map[string]string{"grace_period": duration.Format(s.grace)}}
+	if !marked {
+		reason.Message = "resource wasn't marked as unused by a previous run"
+		return reason
+	}
+
+	elapsed := s.now.Sub(markedAt)
+	reason.Evidence["marked_at"] = markedAt.Format(time.RFC3339)
+	if elapsed < s.grace {
+		reason.Message = fmt.Sprintf("resource was marked as unused %s ago, before the grace period of %s elapsed", duration.Format(elapsed), duration.Format(s.grace))
+		return reason
+	}
+
+	reason.Decision = resource.Deletable
+	reason.Message = fmt.Sprintf("resource was marked as unused %s ago, longer than the grace period of %s", duration.Format(elapsed), duration.Format(s.grace))
+	return reason
+}
+
+// Grace period of the service passed as parameter. The flag takes precedence over the default of the service in the
+// config file
+func gracePeriodFor(cmd *cobra.Command, serviceName string) (time.Duration, error) {
+	value := gracePeriod
+	key := fmt.Sprintf("services.%s.grace_period", serviceName)
+	if !cmd.Flags().Changed("grace-period") && viper.IsSet(key) {
+		value = viper.GetString(key)
+	}
+
+	grace, err := duration.Parse(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid grace period for service '%s': %w", serviceName, err)
+	}
+	if grace < 0 {
+		return 0, fmt.Errorf("grace period for service '%s' must not be negative, got: %s", serviceName, value)
+	}
+	return grace, nil
+}
@@ -0,0 +1,195 @@
+package cleaner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/helpers/duration"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Version of the result document schema. Bump it whenever a field is removed or changes its meaning.
+const schemaVersion = 1
+
+// Formats results can be written in. 'text' only logs them, as it used to be
+var outputFormats = []string{"text", "json", "ndjson", "csv", "table"}
+
+// What happened to a resource
+type outcome string
+
+const (
+	outcomeListed    outcome = "listed"
+	outcomeDeletable outcome = "deletable"
+	outcomeKept      outcome = "kept"
+	outcomeDeleted   outcome = "deleted"
+	outcomeSkipped   outcome = "skipped"
+	outcomeFailed    outcome = "failed"
+	outcomeMarked    outcome = "marked"
+	outcomeUnmarked  outcome = "unmarked"
+)
+
+// Outcome of a single resource, as written to stdout
+type result struct {
+	Service  string            `json:"service"`
+	Account  string            `json:"account,omitempty"`
+	Region   string            `json:"region,omitempty"`
+	ID       string            `json:"id"`
+	Name     string            `json:"name,omitempty"`
+	Type     string            `json:"type,omitempty"`
+	Age      string            `json:"age,omitempty"`
+	Outcome  outcome           `json:"outcome"`
+	Decision resource.Decision `json:"decision,omitempty"`
+	Reasons  []resource.Reason `json:"reasons,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// Result document written in the 'json' format
+type document struct {
+	SchemaVersion int      `json:"schema_version"`
+	Command       string   `json:"command"`
+	Results       []result `json:"results"`
+	Summary       struct {
+		Succeeded int `json:"succeeded"`
+		Skipped   int `json:"skipped"`
+		Failed    int `json:"failed"`
+	} `json:"summary"`
+}
+
+// Outcome of a resource, leaving the service to be filled in by the report
+func newResult(res resource.Resource, outcome outcome, verdict resource.Verdict, err error) result {
+	r := result{
+		Account:  res.Account,
+		Region:   res.Region,
+		ID:       res.ID,
+		Name:     res.Name,
+		Type:     res.Type,
+		Outcome:  outcome,
+		Decision: verdict.Decision,
+		Reasons:  verdict.Reasons,
+		Age:      resultAge(res, verdict),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// Age of a resource. Resources that don't tell when they were created are aged from when they were first seen, as
+// long as their minimum age was checked
+func resultAge(res resource.Resource, verdict resource.Verdict) string {
+	if !res.CreatedAt.IsZero() {
+		return duration.Format(time.Since(res.CreatedAt))
+	}
+	for _, reason := range verdict.Reasons {
+		if reason.Rule == minAgeRule {
+			return reason.Evidence["age"]
+		}
+	}
+	return ""
+}
+
+// Outcome of a resource that couldn't be validated, or was never validated because the sweep was interrupted
+func failedResult(res resource.Resource, err error) result {
+	if err == nil {
+		return newResult(res, outcomeSkipped, resource.Verdict{}, nil)
+	}
+	return newResult(res, outcomeFailed, resource.Verdict{}, err)
+}
+
+// Outcome of a resource that was validated but not deleted
+func verdictResult(res resource.Resource, verdict resource.Verdict) result {
+	if verdict.Deletable() {
+		return newResult(res, outcomeDeletable, verdict, nil)
+	}
+	return newResult(res, outcomeKept, verdict, nil)
+}
+
+// Results of every service, with the service they belong to
+func (r report) results() []result {
+	results := []result{}
+	for _, s := range r {
+		for _, res := range s.results {
+			res.Service = s.service.Name
+			if res.Account == "" {
+				res.Account = s.service.Account
+			}
+			if res.Region == "" {
+				res.Region = s.service.Region
+			}
+			results = append(results, res)
+		}
+	}
+	return results
+}
+
+// Write the results of the command to w in the format passed as parameter. Nothing is written in the 'text' format
+func (r report) write(w io.Writer, format string, command string) error {
+	results := r.results()
+
+	switch format {
+	case "json":
+		doc := document{SchemaVersion: schemaVersion, Command: command, Results: results}
+		total := r.total()
+		doc.Summary.Succeeded, doc.Summary.Skipped, doc.Summary.Failed = total.succeeded, total.skipped, len(total.failures)
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		for _, res := range results {
+			if err := encoder.Encode(res); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"service", "account", "region", "id", "name", "type", "age", "outcome", "decision", "reasons", "error"})
+		for _, res := range results {
+			writer.Write([]string{res.Service, res.Account, res.Region, res.ID, res.Name, res.Type, res.Age, string(res.Outcome), string(res.Decision), res.reasons(), res.Error})
+		}
+		writer.Flush()
+		return writer.Error()
+	case "table":
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "SERVICE\tACCOUNT\tREGION\tID\tNAME\tAGE\tOUTCOME\tREASONS")
+		for _, res := range results {
+			reasons := res.reasons()
+			if res.Error != "" {
+				reasons = res.Error
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", res.Service, dash(res.Account), dash(res.Region), res.ID, dash(res.Name), dash(res.Age), res.Outcome, dash(reasons))
+		}
+		return writer.Flush()
+	}
+
+	return nil
+}
+
+// Messages of the reasons backing the decision in a single line
+func (r result) reasons() string {
+	return resource.Verdict{Decision: r.Decision, Reasons: r.Reasons}.String()
+}
+
+// Placeholder for empty table cells, so columns stay readable
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// Make sure the output format is supported
+func checkOutputFormat(format string) error {
+	if !slices.Contains(outputFormats, format) {
+		return fmt.Errorf("'output' flag must be one of %s, got: %s", strings.Join(outputFormats, ", "), format)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package cleaner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers"
+)
+
+// Version of the plan file format. Bump it whenever the structure below changes in a non-compatible way.
+const planVersion = 1
+
+// Deletion plan persisted by the 'plan' command and consumed by the 'apply' command
+type plan struct {
+	Version   int         `json:"version"`
+	CreatedAt time.Time   `json:"created_at"`
+	Provider  string      `json:"provider"`
+	Services  []string    `json:"services"`
+	Resources []planEntry `json:"resources"`
+}
+
+// Resource that was found to be deletable when the plan was created
+type planEntry struct {
+	Service     string    `json:"service"`
+	Account     string    `json:"account,omitempty"`
+	Region      string    `json:"region"`
+	ID          string    `json:"id"`
+	Reason      string    `json:"reason"`
+	ValidatedAt time.Time `json:"validated_at"`
+}
+
+// Plan entries belonging to the service passed as parameter
+func (p *plan) entries(service providers.Service) []planEntry {
+	var entries []planEntry
+	for _, entry := range p.Resources {
+		if entry.Service == service.Name && entry.Account == service.Account && entry.Region == service.Region {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Make sure every entry belongs to one of the services passed as parameter, so no account or region is left behind
+func (p *plan) checkTargets(services []providers.Service) error {
+	for _, entry := range p.Resources {
+		loaded := slices.ContainsFunc(services, func(service providers.Service) bool {
+			return entry.Service == service.Name && entry.Account == service.Account && entry.Region == service.Region
+		})
+		if !loaded {
+			return fmt.Errorf("plan has resource '%s' of service '%s' in account '%s' and region '%s', but they're not configured", entry.ID, entry.Service, entry.Account, entry.Region)
+		}
+	}
+
+	return nil
+}
+
+// Find every resource of the service passed as parameter that can be excluded, so it can be added to a plan.
+// Entries are returned without the service, account and region, which are known by the caller.
+// In keep-going mode the entries are returned along with the validation failures, which are left out of them
+func planService(ctx context.Context, service providers.Cleanable, serviceName string) ([]planEntry, summary, error) {
+	var (
+		entries []planEntry
+		sum     summary
+	)
+
+	logger.Log(ctx, "info", fmt.Sprintf("Planning deletion of resources for service: %s", serviceName))
+
+	// List all resources for the given service
+	resources, err := service.List(ctx)
+	if err != nil {
+		return nil, sum, fmt.Errorf("error listing resources for service '%s': %w", serviceName, err)
+	}
+
+	results, err := validateAll(ctx, service, serviceName, resources)
+	if err != nil {
+		return nil, sum, err
+	}
+
+	// Only resources that can be excluded are added to the plan
+	for i, resource := range resources {
+		verdict := results[i].verdict
+		sum.add(verdict.Deletable(), results[i].err)
+		if !results[i].validated {
+			sum.record(failedResult(resource, results[i].err))
+			continue
+		}
+		sum.record(verdictResult(resource, verdict))
+
+		if !verdict.Deletable() {
+			logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is not empty and won't be added to the plan.", resource, serviceName), verdictAttrs(verdict)...)
+			continue
+		}
+
+		logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is empty and will be added to the plan.", resource, serviceName), verdictAttrs(verdict)...)
+		entries = append(entries, planEntry{
+			ID:          resource.ID,
+			Reason:      verdict.String(),
+			ValidatedAt: time.Now().UTC(),
+		})
+	}
+
+	logger.Log(ctx, "debug", fmt.Sprintf("Planning completed for service: %s", serviceName))
+	return entries, sum, sum.err()
+}
+
+// Persist the plan as a JSON file
+func writePlan(path string, p *plan) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding plan: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("error writing plan file '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// Read a plan previously written by writePlan
+func readPlan(path string) (*plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file '%s': %w", path, err)
+	}
+
+	p := new(plan)
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("error decoding plan file '%s': %w", path, err)
+	}
+
+	if p.Version != planVersion {
+		return nil, fmt.Errorf("plan file '%s' has version %d, but only version %d is supported", path, p.Version, planVersion)
+	}
+
+	if p.Provider == "" || len(p.Services) == 0 {
+		return nil, fmt.Errorf("plan file '%s' must have both provider and services set", path)
+	}
+
+	return p, nil
+}
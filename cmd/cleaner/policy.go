@@ -0,0 +1,116 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/config"
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/policy"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/spf13/viper"
+)
+
+// Service whose resources are validated against its rules in the policy file along with its own checks, or only
+// against those rules when they replace the checks. Rules are evaluated against the metadata resources were listed with
+type policyService struct {
+	providers.Cleanable
+	policy policy.ServicePolicy
+	now    time.Time
+	listing
+}
+
+// Wrap the services passed as parameter with their rules in the policy file ('policy_file'), if any
+func withPolicy(services []providers.Service, now time.Time) error {
+	path := viper.GetString("policy_file")
+	if path == "" {
+		return nil
+	}
+
+	p, err := config.LoadPolicy(path)
+	if err != nil {
+		return err
+	}
+
+	for i, service := range services {
+		rules, ok := p.Services[service.Name]
+		if !ok || (len(rules.Rules) == 0 && !rules.Replace) {
+			continue
+		}
+		if rules.Replace {
+			if err := checkReplace(service, rules); err != nil {
+				return err
+			}
+		}
+		services[i].Cleanable = &policyService{Cleanable: service.Cleanable, policy: rules, now: now}
+	}
+	return nil
+}
+
+// Make sure the rules of the policy can replace the checks of the service: rules only look at the metadata resources
+// were listed with, so the checks must not look at anything else (like the listeners of LBs), and the rules must look
+// at one of the attributes the checks do at least
+func checkReplace(service providers.Service, rules policy.ServicePolicy) error {
+	checker, ok := unwrap(service.Cleanable).(providers.MetadataChecker)
+	if !ok {
+		return fmt.Errorf("rules of service '%s' can't replace its checks, since they look at more than the metadata of its resources", service.Name)
+	}
+
+	checked := checker.CheckedAttributes()
+	for _, attribute := range rules.Attributes() {
+		if slices.Contains(checked, attribute) {
+			return nil
+		}
+	}
+	return fmt.Errorf("rules replacing the checks of service '%s' must look at its %s attribute(s)", service.Name, strings.Join(checked, ", "))
+}
+
+func (s *policyService) Unwrap() providers.Cleanable {
+	return s.Cleanable
+}
+
+func (s *policyService) rules() []string {
+	rules := make([]string, len(s.policy.Rules))
+	for i, rule := range s.policy.Rules {
+		rules[i] = rule.Name
+	}
+	return rules
+}
+
+func (s *policyService) List(ctx context.Context) ([]resource.Resource, error) {
+	resources, err := s.Cleanable.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store(resources)
+	return resources, nil
+}
+
+func (s *policyService) Validate(ctx context.Context, id string) (resource.Verdict, error) {
+	res, found, err := s.lookup(ctx, s.Cleanable, id)
+	if err != nil {
+		return resource.Verdict{}, err
+	}
+
+	if !s.policy.Replace {
+		verdict, err := s.Cleanable.Validate(ctx, id)
+		if err != nil || !found {
+			return verdict, err
+		}
+		return resource.NewVerdict(append(slices.Clone(verdict.Reasons), s.policy.Evaluate(res, s.now)...)...), nil
+	}
+
+	// Without the checks of the service, resources that weren't found can't be evaluated at all
+	if !found {
+		return resource.NewVerdict(resource.Reason{Rule: "found", Decision: resource.Unknown, Message: "resource was not found"}), nil
+	}
+	reasons := s.policy.Evaluate(res, s.now)
+	if len(reasons) == 0 {
+		reasons = []resource.Reason{{Rule: "policy", Decision: resource.Unknown, Message: "no rule of the policy applies to the resource"}}
+	}
+	return resource.NewVerdict(reasons...), nil
+}
@@ -0,0 +1,62 @@
+package cleaner
+
+import (
+	"context"
+	"sync"
+)
+
+// Call fn for every index in [0, n) using at most 'workers' goroutines at the same time.
+// fn must only write to data owned by its own index, so callers can report results in order once it returns.
+// The first error returned by fn cancels the remaining work and is returned, as is the context's error when
+// it's cancelled before every index was handed out.
+func forEach(ctx context.Context, workers int, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		indexes  = make(chan int)
+	)
+
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := 0; i < n; i++ {
+		// Checked first because select picks randomly when both cases are ready
+		if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,128 @@
+package cleaner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Operator asked for the resources to be deleted when running interactively. A nil prompt deletes every candidate
+type prompt struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// Whether the standard input is an interactive terminal
+var isTerminal = func() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Prompt asking for deletions to be confirmed, unless the '--yes' flag was set. Confirmation can only be asked on a
+// terminal, so the flag is required otherwise
+func loadPicker() (*prompt, error) {
+	if yes {
+		return nil, nil
+	}
+	if !isTerminal() {
+		return nil, errors.New("deleting resources requires confirmation: pass the '--yes' flag when not running on a terminal")
+	}
+	return &prompt{in: bufio.NewReader(stdin), out: os.Stderr}, nil
+}
+
+// Show the candidates to be deleted and ask which of them should really be deleted: all of them, a subset picked by
+// their numbers or none at all. Returns the positions of the picked resources, out of the candidates passed as parameter
+func (p *prompt) pick(serviceName string, resources []resource.Resource, results []deletion, candidates []int) ([]int, error) {
+	if p == nil || len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	fmt.Fprintf(p.out, "%d resource(s) of service '%s' can be deleted:\n", len(candidates), serviceName)
+	tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	for i, candidate := range candidates {
+		res := resources[candidate]
+		created := ""
+		if !res.CreatedAt.IsZero() {
+			created = res.CreatedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "  %d)\t%s\t%s\t%s\t%s\t%s\n", i+1, res.ID, dash(res.Name), dash(res.Type), dash(created), results[candidate].verdict)
+	}
+	if err := tw.Flush(); err != nil {
+		return nil, fmt.Errorf("error writing resources to be deleted: %w", err)
+	}
+
+	for {
+		fmt.Fprint(p.out, "Delete [a]ll of them, pick them by number (like 1,3-5) or [n]one of them: ")
+		line, err := p.in.ReadString('\n')
+		// Running out of input is the same as not confirming anything
+		if errors.Is(err, io.EOF) && line == "" {
+			fmt.Fprintln(p.out)
+			return nil, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("error reading confirmation: %w", err)
+		}
+
+		answer := strings.ToLower(strings.TrimSpace(line))
+		switch answer {
+		case "a", "all", "y", "yes":
+			return candidates, nil
+		case "", "n", "none", "no":
+			return nil, nil
+		}
+
+		picked, err := parseSelection(answer, len(candidates))
+		if err != nil {
+			fmt.Fprintf(p.out, "Invalid answer: %v\n", err)
+			continue
+		}
+		selected := make([]int, len(picked))
+		for i, number := range picked {
+			selected[i] = candidates[number-1]
+		}
+		return selected, nil
+	}
+}
+
+// Parse a comma-separated list of numbers and ranges (like 1,3-5) between 1 and max, returning them in ascending order
+// without repetitions
+func parseSelection(answer string, max int) ([]int, error) {
+	chosen := make([]bool, max+1)
+	for _, part := range strings.Split(answer, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number or a range of numbers", part)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(strings.TrimSpace(to))
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a number or a range of numbers", part)
+			}
+		}
+		if first < 1 || last > max || first > last {
+			return nil, fmt.Errorf("'%s' is out of range, resources go from 1 to %d", part, max)
+		}
+		for i := first; i <= last; i++ {
+			chosen[i] = true
+		}
+	}
+
+	var picked []int
+	for i, ok := range chosen {
+		if ok {
+			picked = append(picked, i)
+		}
+	}
+	return picked, nil
+}
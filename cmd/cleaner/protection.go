@@ -0,0 +1,186 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/spf13/viper"
+)
+
+// Tags protecting resources from being deleted when the config file doesn't set any
+var defaultProtectionTags = []string{"cleanup-ignore=true"}
+
+// Tag protecting the resources that have it. An empty value matches any value of the key
+type protectionTag struct {
+	key   string
+	value string
+}
+
+func (t protectionTag) String() string {
+	if t.value == "" {
+		return t.key
+	}
+	return t.key + "=" + t.value
+}
+
+// Service whose resources are kept when they're skipped by its resource lists or have any of the protection tags.
+// Resources with an expiry tag are kept until they expire and can be deleted afterwards, even when they're in use.
+// Resources are validated by the service either way, so its reasons are still shown along with the protection ones
+type protectedService struct {
+	providers.Cleanable
+	lists resourceLists
+	tags  []protectionTag
+	now   time.Time
+	listing
+}
+
+// Wrap every service passed as parameter with its resource lists, with the protection tags of the config file
+// ('protection_tags'), which are shared by all of them, and with the expiry tag
+func withProtection(services []providers.Service, now time.Time) error {
+	entries := defaultProtectionTags
+	if viper.IsSet("protection_tags") {
+		entries = viper.GetStringSlice("protection_tags")
+	}
+
+	tags := make([]protectionTag, 0, len(entries))
+	for _, entry := range entries {
+		key, value, _ := strings.Cut(entry, "=")
+		if key == "" {
+			return fmt.Errorf("protection tag '%s' must be either a key or a key=value pair", entry)
+		}
+		tags = append(tags, protectionTag{key: key, value: value})
+	}
+
+	file, err := loadListsFile()
+	if err != nil {
+		return err
+	}
+
+	for i, service := range services {
+		lists, err := resourceListsFor(service.Name, file)
+		if err != nil {
+			return err
+		}
+		services[i].Cleanable = &protectedService{Cleanable: service.Cleanable, lists: lists, tags: tags, now: now}
+	}
+	return nil
+}
+
+func (s *protectedService) Unwrap() providers.Cleanable {
+	return s.Cleanable
+}
+
+func (s *protectedService) List(ctx context.Context) ([]resource.Resource, error) {
+	resources, err := s.Cleanable.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store(resources)
+	return resources, nil
+}
+
+func (s *protectedService) Validate(ctx context.Context, id string) (resource.Verdict, error) {
+	res, _, err := s.lookup(ctx, s.Cleanable, id)
+	if err != nil {
+		return resource.Verdict{}, err
+	}
+
+	verdict, err := s.Cleanable.Validate(ctx, id)
+	if err != nil {
+		return verdict, err
+	}
+	reasons := slices.Clone(verdict.Reasons)
+
+	if reason, ok := s.protection(res); ok {
+		return resource.NewVerdict(append(reasons, reason)...), nil
+	}
+	if value, ok := res.Tags[resource.ExpiryTag]; ok {
+		reason := s.expiryReason(value)
+		if reason.Decision != resource.Deletable {
+			return resource.NewVerdict(append(reasons, reason)...), nil
+		}
+
+		// Expired resources are deletable even when the service finds them in use, while its reasons are still shown.
+		// They're still kept when the service can't tell, and when they're too young or kept by the policy
+		rules := s.wrapperRules()
+		decided := slices.DeleteFunc(slices.Clone(reasons), func(r resource.Reason) bool {
+			return r.Decision == resource.Keep && !slices.Contains(rules, r.Rule)
+		})
+		return resource.Verdict{Decision: resource.NewVerdict(append(decided, reason)...).Decision, Reasons: append(reasons, reason)}, nil
+	}
+	return verdict, nil
+}
+
+// Rules of the reasons added by the wrappers of the service, as opposed to the ones given by its own checks
+func (s *protectedService) wrapperRules() []string {
+	var rules []string
+	service := s.Cleanable
+	for {
+		if wrapped, ok := service.(ruleWrapper); ok {
+			rules = append(rules, wrapped.rules()...)
+		}
+		wrapped, ok := service.(wrapper)
+		if !ok {
+			return rules
+		}
+		service = wrapped.Unwrap()
+	}
+}
+
+// Resources skipped by the resource lists or having protection tags are never deleted, even when they're deleted
+// without being validated first
+func (s *protectedService) Delete(ctx context.Context, id string) error {
+	res, _, err := s.lookup(ctx, s.Cleanable, id)
+	if err != nil {
+		return err
+	}
+
+	if reason, ok := s.protection(res); ok {
+		return fmt.Errorf("resource '%v' is protected from being deleted: %s", res, reason.Message)
+	}
+	return s.Cleanable.Delete(ctx, id)
+}
+
+// Reason keeping a resource skipped by the resource lists or having any of the protection tags, if any
+func (s *protectedService) protection(res resource.Resource) (resource.Reason, bool) {
+	if reason, ok := s.lists.reason(res); ok {
+		return reason, true
+	}
+
+	for _, tag := range s.tags {
+		value, ok := res.Tags[tag.key]
+		if ok && (tag.value == "" || tag.value == value) {
+			return resource.Reason{
+				Rule:     "protection-tag",
+				Decision: resource.Keep,
+				Message:  fmt.Sprintf("resource has the %s protection tag", tag),
+				Evidence: map[string]string{tag.key: value},
+			}, true
+		}
+	}
+	return resource.Reason{}, false
+}
+
+// Reason given by the expiry tag of a resource, which doesn't depend on whether the resource is used or not
+func (s *protectedService) expiryReason(value string) resource.Reason {
+	reason := resource.Reason{Rule: "expiry", Evidence: map[string]string{resource.ExpiryTag: value}}
+	expiresAt, err := resource.ParseExpiry(value)
+	switch {
+	case err != nil:
+		reason.Decision = resource.Unknown
+		reason.Message = fmt.Sprintf("resource has an invalid %s tag: %v", resource.ExpiryTag, err)
+	case s.now.Before(expiresAt):
+		reason.Decision = resource.Keep
+		reason.Message = fmt.Sprintf("resource is leased until %s", expiresAt.Format(time.RFC3339))
+	default:
+		reason.Decision = resource.Deletable
+		reason.Message = fmt.Sprintf("resource expired at %s", expiresAt.Format(time.RFC3339))
+	}
+	return reason
+}
@@ -0,0 +1,148 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/helpers/duration"
+	"github.com/loureirovinicius/cleanup/helpers/state"
+	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// How long resources must have been seen unused before being deleted. Its zero value doesn't require anything
+type idlePolicy struct {
+	minRuns     int
+	minDuration time.Duration
+}
+
+func (p idlePolicy) enabled() bool {
+	return p.minRuns > 0 || p.minDuration > 0
+}
+
+// Reason telling whether a resource has been seen unused for long enough
+func (p idlePolicy) reason(entry state.Entry, run time.Time) resource.Reason {
+	idle := run.Sub(entry.FirstIdleAt)
+	reason := resource.Reason{
+		Rule:     "idle",
+		Decision: resource.Deletable,
+		Message:  fmt.Sprintf("resource was seen unused in %d run(s) over %s", entry.IdleRuns, duration.Format(idle)),
+		Evidence: map[string]string{"idle_runs": fmt.Sprint(entry.IdleRuns), "first_idle_at": entry.FirstIdleAt.Format(time.RFC3339)},
+	}
+
+	switch {
+	case entry.IdleRuns < p.minRuns:
+		reason.Decision = resource.Keep
+		reason.Message += fmt.Sprintf(", fewer than the %d run(s) required", p.minRuns)
+	case idle < p.minDuration:
+		reason.Decision = resource.Keep
+		reason.Message += fmt.Sprintf(", less than the %s required", duration.Format(p.minDuration))
+	}
+	return reason
+}
+
+// Service whose verdicts are recorded in the state store, and whose unused resources are only deletable once they have
+// been seen unused for as long as the policy requires
+type trackedService struct {
+	providers.Cleanable
+	store  *state.Store
+	policy idlePolicy
+	prefix string
+	run    time.Time
+}
+
+// Wrap the service passed as parameter so its verdicts are recorded in the store. It's returned as it is when there's
+// no store to record them in
+func withState(service providers.Service, store *state.Store, policy idlePolicy, run time.Time) providers.Cleanable {
+	if store == nil {
+		return service
+	}
+	return &trackedService{Cleanable: service, store: store, policy: policy, prefix: statePrefix(service), run: run}
+}
+
+// Prefix of the keys of the resources of the service passed as parameter in the state store
+func statePrefix(service providers.Service) string {
+	return strings.Join([]string{service.Name, service.Account, service.Region}, "/") + "/"
+}
+
+func (s *trackedService) List(ctx context.Context) ([]resource.Resource, error) {
+	resources, err := s.Cleanable.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resources filtered out may still exist, so they're only forgotten when every resource was listed
+	if filterExpr == nil {
+		s.store.Prune(s.prefix, resource.IDs(resources))
+	}
+	return resources, nil
+}
+
+func (s *trackedService) Unwrap() providers.Cleanable {
+	return s.Cleanable
+}
+
+func (s *trackedService) Validate(ctx context.Context, id string) (resource.Verdict, error) {
+	verdict, err := s.Cleanable.Validate(ctx, id)
+	if err != nil {
+		return verdict, err
+	}
+
+	entry := s.store.Observe(s.prefix+id, verdict.Decision, s.run)
+	if !verdict.Deletable() || !s.policy.enabled() {
+		return verdict, nil
+	}
+	return resource.NewVerdict(append(slices.Clone(verdict.Reasons), s.policy.reason(entry, s.run))...), nil
+}
+
+// Load the state file, if any. The flag takes precedence over the config file, and there's no store when neither of
+// them set it
+func loadState(cmd *cobra.Command) (*state.Store, error) {
+	path := stateFile
+	if !cmd.Flags().Changed("state-file") && viper.IsSet("state_file") {
+		path = viper.GetString("state_file")
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return state.Load(path)
+}
+
+// Write the store back to the state file, if any
+func saveState(store *state.Store) error {
+	if store == nil {
+		return nil
+	}
+	return store.Save()
+}
+
+// Idle policy of the service passed as parameter. Flags take precedence over the defaults of the service in the config
+// file
+func idlePolicyFor(cmd *cobra.Command, serviceName string) (idlePolicy, error) {
+	runs, value := minIdleRuns, minIdleDuration
+	if key := fmt.Sprintf("services.%s.min_idle_runs", serviceName); !cmd.Flags().Changed("min-idle-runs") && viper.IsSet(key) {
+		runs = viper.GetInt(key)
+	}
+	if key := fmt.Sprintf("services.%s.min_idle_duration", serviceName); !cmd.Flags().Changed("min-idle-duration") && viper.IsSet(key) {
+		value = viper.GetString(key)
+	}
+
+	policy := idlePolicy{minRuns: runs}
+	if value != "" {
+		d, err := duration.Parse(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid minimum idle duration for service '%s': %w", serviceName, err)
+		}
+		policy.minDuration = d
+	}
+
+	if policy.minRuns < 0 || policy.minDuration < 0 {
+		return policy, fmt.Errorf("minimum idle runs and duration for service '%s' must not be negative", serviceName)
+	}
+	return policy, nil
+}
@@ -0,0 +1,109 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers"
+)
+
+// What happened to the resources of a service during a sweep
+type summary struct {
+	succeeded int
+	skipped   int
+	failures  []error
+	results   []result
+}
+
+// Record the outcome of a single resource. A failure always takes precedence over the other outcomes
+func (s *summary) add(succeeded bool, err error) {
+	switch {
+	case err != nil:
+		s.failures = append(s.failures, err)
+	case succeeded:
+		s.succeeded++
+	default:
+		s.skipped++
+	}
+}
+
+// Record the outcome of a single resource to be written as a result
+func (s *summary) record(r result) {
+	s.results = append(s.results, r)
+}
+
+// Join every failure into a single error, so partial failures can still be detected by callers
+func (s *summary) err() error {
+	return errors.Join(s.failures...)
+}
+
+// Summary of a single service, as part of a report
+type serviceSummary struct {
+	service providers.Service
+	summary
+}
+
+// Consolidated summaries of every service swept during the execution, in the order they were requested
+type report []serviceSummary
+
+// Sum up the summaries of every service
+func (r report) total() summary {
+	var total summary
+	for _, s := range r {
+		total.succeeded += s.succeeded
+		total.skipped += s.skipped
+		total.failures = append(total.failures, s.failures...)
+	}
+	return total
+}
+
+// Log how many resources succeeded, were skipped and failed for each service and, when more than one service was
+// swept, for all of them together
+func (r report) log(ctx context.Context) {
+	for _, s := range r {
+		serviceCtx := logger.WithAttrs(ctx, s.service.LogAttrs()...)
+		logger.Log(serviceCtx, "info", fmt.Sprintf("Summary for service '%s': %d succeeded, %d skipped, %d failed", s.service, s.succeeded, s.skipped, len(s.failures)))
+	}
+
+	if len(r) > 1 {
+		total := r.total()
+		logger.Log(ctx, "info", fmt.Sprintf("Summary for all services: %d succeeded, %d skipped, %d failed", total.succeeded, total.skipped, len(total.failures)))
+	}
+}
+
+// Run fn for every service, collecting their summaries into a single report. Unless in keep-going mode, the first
+// service failing stops the remaining ones from being swept. The context passed to fn tags logs with the service
+func sweep(ctx context.Context, services []providers.Service, fn func(context.Context, providers.Service) (summary, error)) (report, error) {
+	var (
+		rep  report
+		errs []error
+	)
+
+	for _, service := range services {
+		sum, err := fn(logger.WithAttrs(ctx, service.LogAttrs()...), service)
+		// Failures preventing the whole service from being swept (like listing it) count as a single failure
+		if err != nil && len(sum.failures) == 0 {
+			sum.failures = append(sum.failures, err)
+		}
+		rep = append(rep, serviceSummary{service: service, summary: sum})
+
+		if err != nil {
+			errs = append(errs, err)
+			if !keepGoing {
+				break
+			}
+		}
+	}
+
+	return rep, errors.Join(errs...)
+}
+
+// Error to be returned to the worker pool. In keep-going mode failures are only recorded so the sweep isn't interrupted
+func halt(err error) error {
+	if keepGoing {
+		return nil
+	}
+	return err
+}
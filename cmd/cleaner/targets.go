@@ -0,0 +1,112 @@
+package cleaner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// IDs or ARNs of the only resources a command acts on, telling which of them were found in the services swept.
+// A nil set targets every resource
+type targetSet struct {
+	mu      sync.Mutex
+	ids     []string
+	matched map[string]bool
+}
+
+// Build the target set from the '--id' and '--ids-from' flags. It's nil when neither of them was set
+func loadTargets(ids []string, from string) (*targetSet, error) {
+	if from != "" {
+		read, err := readIDs(from)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, read...)
+	}
+
+	if len(ids) == 0 {
+		if from != "" {
+			return nil, fmt.Errorf("no resource IDs were found in '%s'", from)
+		}
+		return nil, nil
+	}
+
+	return &targetSet{ids: ids, matched: map[string]bool{}}, nil
+}
+
+// Read newline-separated IDs from a file, or from stdin when it's '-'. Blank lines and lines starting with '#' are
+// ignored
+func readIDs(from string) ([]string, error) {
+	var r io.Reader = stdin
+	if from != "-" {
+		file, err := os.Open(from)
+		if err != nil {
+			return nil, fmt.Errorf("error reading resource IDs: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading resource IDs: %w", err)
+	}
+
+	return ids, nil
+}
+
+// Keep only the targeted resources, matching them by ID or ARN
+func (t *targetSet) filter(resources []resource.Resource) []resource.Resource {
+	if t == nil {
+		return resources
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var targeted []resource.Resource
+	for _, res := range resources {
+		for _, id := range t.ids {
+			if res.ID == id || (res.ARN != "" && res.ARN == id) {
+				t.matched[id] = true
+				targeted = append(targeted, res)
+				break
+			}
+		}
+	}
+	return targeted
+}
+
+// Error listing the targeted IDs that weren't found in any of the services swept, which are never acted on
+func (t *targetSet) err(services string) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var unknown []string
+	for _, id := range t.ids {
+		if !t.matched[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	return fmt.Errorf("resource(s) %s don't belong to service(s) '%s' and were refused", strings.Join(unknown, ", "), services)
+}
@@ -6,33 +6,87 @@ import (
 
 	"github.com/loureirovinicius/cleanup/helpers/logger"
 	"github.com/loureirovinicius/cleanup/providers"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 )
 
-// Validate instances of the service passed as parameter to check whether it's being used or not
-func validate(ctx context.Context, service providers.Cleanable, serviceName string) error {
+// Validate instances of the service passed as parameter to check whether it's being used or not.
+// Resources that can be excluded are counted as succeeded in the returned summary
+func validate(ctx context.Context, service providers.Cleanable, serviceName string) (summary, error) {
+	var sum summary
+
 	logger.Log(ctx, "info", fmt.Sprintf("Validating resources for service: %s", serviceName))
 
-	// List all resources for the given service
-	resources, err := service.List(ctx)
+	// List the resources of the given service matching the filter, if any
+	resources, err := listFiltered(ctx, service, serviceName)
 	if err != nil {
-		return fmt.Errorf("error listing resources for service '%s': %w", serviceName, err)
+		return sum, err
 	}
+	// Resources that weren't targeted are left untouched, as if they weren't listed
+	resources = targets.filter(resources)
 
-	// Iterate through each resource and validate
-	for _, resource := range resources {
-		empty, err := service.Validate(ctx, resource)
-		if err != nil {
-			return fmt.Errorf("error validating resource '%v' in service '%s': %w", resource, serviceName, err)
-		}
+	// Validate resources concurrently, keeping the results in the same order as the resources
+	results, err := validateAll(ctx, service, serviceName, resources)
 
-		// Log whether each resource can be excluded based on validation
-		if empty {
-			logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is empty and can be excluded.", resource, serviceName))
-		} else {
-			logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is not empty and cannot be excluded.", resource, serviceName))
+	// Log whether each resource can be excluded based on validation
+	for i, resource := range resources {
+		result := results[i]
+		if !result.validated {
+			sum.add(false, result.err)
+			sum.record(failedResult(resource, result.err))
+			continue
 		}
+
+		logVerdict(ctx, resource, serviceName, result.verdict)
+		sum.add(result.verdict.Deletable(), nil)
+		sum.record(verdictResult(resource, result.verdict))
+	}
+
+	if err != nil {
+		return sum, err
 	}
 
 	logger.Log(ctx, "debug", fmt.Sprintf("Validation completed for service: %s", serviceName))
-	return nil
+	return sum, sum.err()
+}
+
+// Outcome of validating a single resource
+type validation struct {
+	validated bool
+	verdict   resource.Verdict
+	err       error
+}
+
+// Validate every resource using the worker pool. Results are in the same order as the resources, and only the ones
+// flagged as validated are meaningful when an error is returned
+func validateAll(ctx context.Context, service providers.Cleanable, serviceName string, resources []resource.Resource) ([]validation, error) {
+	results := make([]validation, len(resources))
+	err := forEach(ctx, concurrency, len(resources), func(ctx context.Context, i int) error {
+		verdict, err := service.Validate(ctx, resources[i].ID)
+		if err != nil {
+			results[i].err = fmt.Errorf("error validating resource '%v' in service '%s': %w", resources[i], serviceName, err)
+			return halt(results[i].err)
+		}
+		results[i] = validation{validated: true, verdict: verdict}
+		return nil
+	})
+
+	return results, err
+}
+
+// Log whether a resource can be excluded, along with the reasons of its verdict
+func logVerdict(ctx context.Context, res any, serviceName string, verdict resource.Verdict) {
+	attrs := verdictAttrs(verdict)
+	switch verdict.Decision {
+	case resource.Deletable:
+		logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is empty and can be excluded.", res, serviceName), attrs...)
+	case resource.Keep:
+		logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' is not empty and cannot be excluded.", res, serviceName), attrs...)
+	default:
+		logger.Log(ctx, "info", fmt.Sprintf("Resource '%v' in service '%s' could not be evaluated and cannot be excluded.", res, serviceName), attrs...)
+	}
+}
+
+// Attributes explaining a verdict in logs
+func verdictAttrs(verdict resource.Verdict) []any {
+	return []any{"decision", string(verdict.Decision), "reasons", verdict.String()}
 }
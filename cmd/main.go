@@ -1,16 +1,13 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/loureirovinicius/cleanup/cmd/cleaner"
 )
 
 func main() {
+	// Errors are already logged by the cleaner, only the exit code is left to be set
 	err := cleaner.Run()
-	if err != nil {
-		fmt.Printf("there was an error running the cleaner: %v\n", err)
-		os.Exit(1)
-	}
+	os.Exit(cleaner.ExitCode(err))
 }
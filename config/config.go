@@ -47,6 +47,11 @@ func loadAWSConfig() error {
 		return fmt.Errorf("error binding region variable: %w", err)
 	}
 
+	// AWS_REGIONS env variable, as a comma-separated list of regions
+	if err := viper.BindEnv("aws.regions", "AWS_REGIONS"); err != nil {
+		return fmt.Errorf("error binding regions variable: %w", err)
+	}
+
 	// AWS_PROFILE_NAME env variable
 	if err := viper.BindEnv("profile.name", "AWS_PROFILE_NAME"); err != nil {
 		return fmt.Errorf("error binding profile_name variable: %w", err)
@@ -3,6 +3,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -54,6 +56,8 @@ func TestLoadAWSConfig(t *testing.T) {
 
 	// Mock environment variables
 	_ = os.Setenv("AWS_REGION", "us-east-1")
+	_ = os.Setenv("AWS_REGIONS", "us-east-1,eu-west-1")
+	defer os.Unsetenv("AWS_REGIONS")
 	_ = os.Setenv("AWS_PROFILE_NAME", "default")
 	_ = os.Setenv("AWS_ACCESS_KEY", "mock-access-key")
 	_ = os.Setenv("AWS_SECRET_KEY", "mock-secret-key")
@@ -67,6 +71,9 @@ func TestLoadAWSConfig(t *testing.T) {
 	if viper.GetString("region") != "us-east-1" {
 		t.Errorf("Expected region to be 'us-east-1', got: %s", viper.GetString("region"))
 	}
+	if viper.GetString("aws.regions") != "us-east-1,eu-west-1" {
+		t.Errorf("Expected aws.regions to be 'us-east-1,eu-west-1', got: %s", viper.GetString("aws.regions"))
+	}
 	if viper.GetString("profile.name") != "default" {
 		t.Errorf("Expected profile.name to be 'default', got: %s", viper.GetString("profile.name"))
 	}
@@ -77,3 +84,52 @@ func TestLoadAWSConfig(t *testing.T) {
 		t.Errorf("Expected credentials.secret_key to be 'mock-secret-key', got: %s", viper.GetString("credentials.secret_key"))
 	}
 }
+
+func TestLoadPolicy(t *testing.T) {
+	// Test case 1: Valid policy file
+	t.Run("Valid policy file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		_ = os.WriteFile(path, []byte(`
+services:
+  ebs:
+    rules:
+      - name: unattached
+        decision: deletable
+        when:
+          all:
+            - attribute: state
+              equals: available
+            - older_than: 7d
+`), 0o600)
+
+		p, err := LoadPolicy(path)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(p.Services["ebs"].Rules) != 1 || p.Services["ebs"].Rules[0].Name != "unattached" {
+			t.Errorf("Expected the unattached rule of ebs, got: %+v", p.Services)
+		}
+	})
+
+	// Test case 2: Unknown fields are refused
+	t.Run("Unknown field", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		_ = os.WriteFile(path, []byte("services:\n  ebs:\n    rules:\n      - name: old\n        decision: deletable\n        when:\n          older_then: 7d\n"), 0o600)
+
+		_, err := LoadPolicy(path)
+		if err == nil || !strings.Contains(err.Error(), "field older_then not found") {
+			t.Errorf("Expected unknown field error, got: %v", err)
+		}
+	})
+
+	// Test case 3: Invalid rule
+	t.Run("Invalid rule", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		_ = os.WriteFile(path, []byte("services:\n  ebs:\n    rules:\n      - decision: keep\n        when:\n          tag: owner\n          exists: true\n"), 0o600)
+
+		_, err := LoadPolicy(path)
+		if err == nil || err.Error() != "error checking policy file: invalid rule 1 of service 'ebs': rules must have a name" {
+			t.Errorf("Expected invalid rule error, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/loureirovinicius/cleanup/providers/policy"
+	"gopkg.in/yaml.v3"
+)
+
+// Load the policy file with the validation rules of the services. Unknown fields are refused, so typos in a rule
+// don't silently relax it
+func LoadPolicy(path string) (*policy.Policy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading policy file: %w", err)
+	}
+	defer file.Close()
+
+	p := &policy.Policy{}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing policy file: %w", err)
+	}
+
+	if err := p.Check(); err != nil {
+		return nil, fmt.Errorf("error checking policy file: %w", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,41 @@
+package duration
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// Parse a duration like time.ParseDuration does, also accepting a whole number of days (like 7d)
+func Parse(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+		return time.Duration(n) * day, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", value)
+	}
+	return d, nil
+}
+
+// Format a duration in a human-readable way, counting days for the long ones and dropping what's below the most
+// significant units (like 3d4h or 2h5m0s)
+func Format(d time.Duration) string {
+	if d < day {
+		return d.Truncate(time.Second).String()
+	}
+
+	days, rest := d/day, (d % day).Truncate(time.Hour)
+	if rest == 0 {
+		return fmt.Sprintf("%dd", days)
+	}
+	return fmt.Sprintf("%dd%s", days, strings.TrimSuffix(rest.String(), "0m0s"))
+}
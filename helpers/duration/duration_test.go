@@ -0,0 +1,72 @@
+package duration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]struct {
+		value    string
+		expected time.Duration
+		err      string
+	}{
+		"Days": {
+			value:    "7d",
+			expected: 7 * 24 * time.Hour,
+		},
+		"Go duration": {
+			value:    "36h30m",
+			expected: 36*time.Hour + 30*time.Minute,
+		},
+		"Invalid days": {
+			value: "1.5d",
+			err:   "invalid duration '1.5d'",
+		},
+		"Invalid duration": {
+			value: "week",
+			err:   "invalid duration 'week'",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			d, err := Parse(test.value)
+
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, d)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cases := map[string]struct {
+		duration time.Duration
+		expected string
+	}{
+		"Less than a day": {
+			duration: 2*time.Hour + 5*time.Minute + 300*time.Millisecond,
+			expected: "2h5m0s",
+		},
+		"Whole days": {
+			duration: 7 * 24 * time.Hour,
+			expected: "7d",
+		},
+		"Days and hours": {
+			duration: 3*24*time.Hour + 4*time.Hour + 59*time.Minute,
+			expected: "3d4h",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, Format(test.duration))
+		})
+	}
+}
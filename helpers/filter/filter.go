@@ -0,0 +1,375 @@
+package filter
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/loureirovinicius/cleanup/helpers/duration"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Filter expression matching resources by their metadata, like tag:team == "data" && size > 100. Comparisons are
+// combined with && (and), || (or), ! (not) and parentheses, and compare a field with a value:
+//   - ==, != compare values, as numbers or durations when both of them are
+//   - <, <=, >, >= order values, as numbers or durations when both of them are and as strings otherwise
+//   - =~, !~ match values against a regular expression
+//
+// Fields are id, arn, name, type, region, account, created_at, age (how long ago it was created, like 7d), tag:<key> and
+// any attribute of the resource (like state or vpc_id). Missing fields are empty, and never ordered against a value.
+// Values are either quoted strings or bare words (like 100, 7d or available)
+type Expr struct {
+	raw  string
+	root node
+}
+
+// Parse a filter expression
+func Parse(raw string) (*Expr, error) {
+	tokens, err := lex(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected '%s' at position %d", t.text, t.pos)
+	}
+	return &Expr{raw: raw, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.raw
+}
+
+// Whether the resource passed as parameter matches the expression. Ages are computed against now
+func (e *Expr) Match(res resource.Resource, now time.Time) bool {
+	return e.root.match(res, now)
+}
+
+// Fields that must equal a value for a resource to match the expression, so they can be pushed down to the provider's
+// API. Only equalities every match depends on (the ones joined by && at the top of the expression) are returned, and
+// only when their value is written in its canonical form, since APIs compare values as text
+func (e *Expr) Pushdown() map[string]string {
+	fields := map[string]string{}
+	var walk func(n node)
+	walk = func(n node) {
+		switch n := n.(type) {
+		case and:
+			walk(n.left)
+			walk(n.right)
+		case comparison:
+			if n.op == "==" && n.value != "" && n.field != "age" && canonical(n.value) {
+				fields[n.field] = n.value
+			}
+		}
+	}
+	walk(e.root)
+	return fields
+}
+
+type node interface {
+	match(resource.Resource, time.Time) bool
+}
+
+type and struct{ left, right node }
+
+func (n and) match(res resource.Resource, now time.Time) bool {
+	return n.left.match(res, now) && n.right.match(res, now)
+}
+
+type or struct{ left, right node }
+
+func (n or) match(res resource.Resource, now time.Time) bool {
+	return n.left.match(res, now) || n.right.match(res, now)
+}
+
+type not struct{ node node }
+
+func (n not) match(res resource.Resource, now time.Time) bool {
+	return !n.node.match(res, now)
+}
+
+type comparison struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (n comparison) match(res resource.Resource, now time.Time) bool {
+	value, ok := field(res, n.field, now)
+	switch n.op {
+	case "=~":
+		return n.re.MatchString(value)
+	case "!~":
+		return !n.re.MatchString(value)
+	case "==":
+		return compare(value, n.value) == 0
+	case "!=":
+		return compare(value, n.value) != 0
+	}
+
+	if !ok {
+		return false
+	}
+	c := compare(value, n.value)
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// Value of a field of the resource, and whether the resource has it
+func field(res resource.Resource, name string, now time.Time) (string, bool) {
+	switch name {
+	case "id":
+		return res.ID, true
+	case "arn":
+		return res.ARN, res.ARN != ""
+	case "name":
+		return res.Name, res.Name != ""
+	case "type":
+		return res.Type, res.Type != ""
+	case "region":
+		return res.Region, res.Region != ""
+	case "account":
+		return res.Account, res.Account != ""
+	case "created_at":
+		if res.CreatedAt.IsZero() {
+			return "", false
+		}
+		return res.CreatedAt.UTC().Format(time.RFC3339), true
+	case "age":
+		if res.CreatedAt.IsZero() {
+			return "", false
+		}
+		return now.Sub(res.CreatedAt).String(), true
+	}
+
+	if key, ok := strings.CutPrefix(name, "tag:"); ok {
+		value, ok := res.Tags[key]
+		return value, ok
+	}
+	value, ok := res.Attributes[name]
+	return value, ok
+}
+
+// Whether the value matches the same values as text as it does through compare: values that aren't numbers nor
+// durations, and integers written without leading zeros, signs or decimals (like 100, unlike 100.0 or 080)
+func canonical(value string) bool {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		n, err := strconv.ParseInt(value, 10, 64)
+		return err == nil && strconv.FormatInt(n, 10) == value
+	}
+	_, err := duration.Parse(value)
+	return err != nil
+}
+
+// Compare two values as numbers or durations when both of them are, and as strings otherwise
+func compare(a, b string) int {
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			return cmp.Compare(x, y)
+		}
+	}
+	if x, err := duration.Parse(a); err == nil {
+		if y, err := duration.Parse(b); err == nil {
+			return cmp.Compare(x, y)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("||") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("&&") {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	t := p.next()
+	switch {
+	case t.is("!"):
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{n}, nil
+	case t.is("("):
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); !closing.is(")") {
+			return nil, fmt.Errorf("expected ')' at position %d", closing.pos)
+		}
+		return n, nil
+	case t.kind == tokenWord:
+		return p.comparison(t)
+	case t.kind == tokenEnd:
+		return nil, errors.New("unexpected end of the filter, expected a comparison")
+	}
+	return nil, fmt.Errorf("unexpected '%s' at position %d, expected a comparison", t.text, t.pos)
+}
+
+func (p *parser) comparison(field token) (node, error) {
+	op := p.next()
+	if op.kind != tokenOperator || !slices.Contains(comparisons, op.text) {
+		return nil, fmt.Errorf("expected a comparison operator after '%s' at position %d", field.text, op.pos)
+	}
+
+	value := p.next()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, fmt.Errorf("expected a value after '%s' at position %d", op.text, value.pos)
+	}
+
+	n := comparison{field: field.text, op: op.text, value: value.text}
+	if op.text == "=~" || op.text == "!~" {
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at position %d: %w", value.pos, err)
+		}
+		n.re = re
+	}
+	return n, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// Whether the token is the operator passed as parameter
+func (t token) is(op string) bool {
+	return t.kind == tokenOperator && t.text == op
+}
+
+// Operators comparing a field with a value
+var comparisons = []string{"==", "!=", "<", "<=", ">", ">=", "=~", "!~"}
+
+// Operators, with the longer ones first so they're matched before their prefixes
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+// Split an expression into words, quoted strings and operators. Positions start at 1
+func lex(raw string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(raw); {
+		c := rune(raw[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(raw) && raw[end] != '"' {
+				if raw[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(raw) {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			text, err := strconv.Unquote(raw[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %w", i+1, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: i + 1})
+			i = end + 1
+		case isWord(c):
+			end := i
+			for end < len(raw) && isWord(rune(raw[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: raw[i:end], pos: i + 1})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(raw[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected '%c' at position %d", c, i+1)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i + 1})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEnd, text: "end of the filter", pos: len(raw) + 1}), nil
+}
+
+// Characters of field names and bare values, which include the ones of tag keys (like aws:cloudformation:stack-name)
+func isWord(c rune) bool {
+	return c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_.:-/@+", c))
+}
@@ -0,0 +1,136 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	volume := resource.Resource{
+		ID:         "vol-1234567890abcdef0",
+		Name:       "data-scratch",
+		Type:       "AWS::EC2::Volume",
+		Tags:       map[string]string{"team": "data", "aws:cloudformation:stack-name": "pipeline"},
+		CreatedAt:  now.Add(-10 * 24 * time.Hour),
+		Attributes: map[string]string{"state": "available", "size": "500", "volume_type": "gp3"},
+	}
+
+	cases := map[string]struct {
+		filter   string
+		expected bool
+	}{
+		"Tag and numeric attribute":        {filter: `tag:team == "data" && size > 100`, expected: true},
+		"Numbers aren't compared as text":  {filter: `size > 60`, expected: true},
+		"Missing tag is empty":             {filter: `tag:owner == ""`, expected: true},
+		"Missing tag is never ordered":     {filter: `tag:owner < "z"`, expected: false},
+		"Tag keys with colons":             {filter: `tag:aws:cloudformation:stack-name == pipeline`, expected: true},
+		"Bare values":                      {filter: `state == available && volume_type != gp2`, expected: true},
+		"Or":                               {filter: `size < 100 || tag:team == data`, expected: true},
+		"Not and parentheses":              {filter: `!(state == available && size >= 500)`, expected: false},
+		"Regular expression":               {filter: `name =~ "^data-"`, expected: true},
+		"Negated regular expression":       {filter: `id !~ "^vol-"`, expected: false},
+		"Age compared as a duration":       {filter: `age > 7d && age <= 240h`, expected: true},
+		"Creation time compared as a text": {filter: `created_at < "2024-05-01"`, expected: true},
+		"And takes precedence over or":     {filter: `size > 1000 && state == available || tag:team == ops`, expected: false},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			expr, err := Parse(c.filter)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, expr.Match(volume, now))
+		})
+	}
+}
+
+func TestMatchLoadBalancerType(t *testing.T) {
+	lb := resource.Resource{
+		ID:         "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/net/web/50dc6c495c0c9188",
+		Type:       "AWS::ElasticLoadBalancingV2::LoadBalancer",
+		Attributes: map[string]string{"lb_type": "network", "scheme": "internal"},
+	}
+
+	cases := map[string]struct {
+		filter   string
+		expected bool
+	}{
+		"Type of the LB":       {filter: `lb_type == network`, expected: true},
+		"Type of the resource": {filter: `type == "AWS::ElasticLoadBalancingV2::LoadBalancer" && lb_type != application`, expected: true},
+		"Type isn't the LB's":  {filter: `type == network`, expected: false},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			expr, err := Parse(c.filter)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, expr.Match(lb, time.Now()))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]struct {
+		filter string
+		err    string
+	}{
+		"Empty filter":           {filter: "", err: "unexpected end of the filter, expected a comparison"},
+		"Missing operator":       {filter: "state available", err: "expected a comparison operator after 'state' at position 7"},
+		"Missing value":          {filter: "size >", err: "expected a value after '>' at position 7"},
+		"Unbalanced parentheses": {filter: "(size > 1", err: "expected ')' at position 10"},
+		"Trailing tokens":        {filter: "size > 1 size", err: "unexpected 'size' at position 10"},
+		"Unterminated string":    {filter: `name == "data`, err: "unterminated string at position 9"},
+		"Unknown character":      {filter: "size > 1 & state == available", err: "unexpected '&' at position 10"},
+		"Invalid regular expression": {
+			filter: `name =~ "("`,
+			err:    "invalid regular expression at position 9: error parsing regexp: missing closing ): `(`",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(c.filter)
+			assert.EqualError(t, err, c.err)
+		})
+	}
+}
+
+func TestPushdown(t *testing.T) {
+	cases := map[string]struct {
+		filter   string
+		expected map[string]string
+	}{
+		"Equalities joined by and": {
+			filter:   `tag:team == "data" && size > 100 && state == available`,
+			expected: map[string]string{"tag:team": "data", "state": "available"},
+		},
+		"Equalities under or aren't required": {
+			filter:   `state == available || tag:team == data`,
+			expected: map[string]string{},
+		},
+		"Negated equalities aren't required": {
+			filter:   `!(state == available) && vpc_id == vpc-1`,
+			expected: map[string]string{"vpc_id": "vpc-1"},
+		},
+		"Empty values": {
+			filter:   `tag:owner == ""`,
+			expected: map[string]string{},
+		},
+		"Values that aren't in their canonical form": {
+			filter:   `size == 100.0 && port == 080 && iops == 3000 && tag:ttl == 7d && state == available`,
+			expected: map[string]string{"iops": "3000", "state": "available"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			expr, err := Parse(c.filter)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, expr.Pushdown())
+		})
+	}
+}
@@ -17,6 +17,9 @@ var (
 	logLevel = &slog.LevelVar{}
 )
 
+// Key used to store log attributes in a context
+type attrsKey struct{}
+
 // Initialize logger
 func InitializeLogger(level string, format string, dst io.Writer) {
 
@@ -48,5 +51,21 @@ func Log(ctx context.Context, level string, msg string, args ...any) {
 	if !ok {
 		lvl = slog.LevelInfo
 	}
-	logger.Log(ctx, lvl, msg, args...)
+	logger.Log(ctx, lvl, msg, append(attrsFromContext(ctx), args...)...)
+}
+
+// Return a copy of the context carrying attributes (key-value pairs) to be added to every log using it
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, attrsKey{}, append(attrsFromContext(ctx), args...))
+}
+
+// Attributes previously stored in the context by WithAttrs
+func attrsFromContext(ctx context.Context) []any {
+	if ctx == nil {
+		return nil
+	}
+
+	attrs, _ := ctx.Value(attrsKey{}).([]any)
+	// Copy the attributes so appending to them never changes the ones stored in the context
+	return append([]any(nil), attrs...)
 }
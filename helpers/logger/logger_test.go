@@ -70,3 +70,39 @@ func TestLog(t *testing.T) {
 		})
 	}
 }
+
+func TestWithAttrs(t *testing.T) {
+	ctx := WithAttrs(context.Background(), "region", "us-east-1")
+	nested := WithAttrs(ctx, "service", "ebs")
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		expectOut []string
+		notOut    []string
+	}{
+		{"attributes are added to the log", ctx, []string{"region=us-east-1"}, []string{"service=ebs"}},
+		{"nested attributes keep the parent ones", nested, []string{"region=us-east-1", "service=ebs"}, nil},
+		{"context without attributes", context.Background(), nil, []string{"region=us-east-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := captureOutput(func(dst io.Writer) {
+				InitializeLogger("info", "text", dst)
+				Log(tt.ctx, "info", "test message", "extra", "value")
+			})
+
+			for _, expected := range append(tt.expectOut, "extra=value") {
+				if !strings.Contains(output, expected) {
+					t.Errorf("expected output to contain %s, got %s", expected, output)
+				}
+			}
+			for _, unexpected := range tt.notOut {
+				if strings.Contains(output, unexpected) {
+					t.Errorf("expected output not to contain %s, got %s", unexpected, output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package snapshot
+
+import "sync"
+
+// Metadata of the resources of a service, captured while listing them so they can be validated without describing
+// each one of them again. The zero value is ready to be used, and it's safe for concurrent use
+type Snapshot[T any] struct {
+	mu    sync.RWMutex
+	items map[string]*T
+}
+
+// Forget every resource, so the snapshot only has the ones stored from now on
+func (s *Snapshot[T]) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = map[string]*T{}
+}
+
+// Store the metadata of a resource, replacing what was known about it
+func (s *Snapshot[T]) Store(id string, item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items == nil {
+		s.items = map[string]*T{}
+	}
+	s.items[id] = &item
+}
+
+// Record that a resource doesn't exist anymore
+func (s *Snapshot[T]) StoreMissing(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items == nil {
+		s.items = map[string]*T{}
+	}
+	s.items[id] = nil
+}
+
+// Metadata of a resource. It's nil when the resource is known to be missing, and 'known' is false when the snapshot
+// has nothing about it, in which case the resource must be described
+func (s *Snapshot[T]) Get(id string) (item *T, known bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, known = s.items[id]
+	return item, known
+}
+
+// Split IDs into batches of at most 'size' of them, so they can be refreshed with as few calls as possible
+func Batches(ids []string, size int) [][]string {
+	var batches [][]string
+	for len(ids) > size {
+		batches = append(batches, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		batches = append(batches, ids)
+	}
+	return batches
+}
@@ -0,0 +1,55 @@
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	var s Snapshot[string]
+
+	_, known := s.Get("vol-1")
+	assert.False(t, known)
+
+	s.Store("vol-1", "available")
+	s.StoreMissing("vol-2")
+
+	item, known := s.Get("vol-1")
+	assert.True(t, known)
+	assert.Equal(t, "available", *item)
+
+	item, known = s.Get("vol-2")
+	assert.True(t, known)
+	assert.Nil(t, item)
+
+	s.Reset()
+	_, known = s.Get("vol-1")
+	assert.False(t, known)
+}
+
+func TestBatches(t *testing.T) {
+	cases := map[string]struct {
+		ids      []string
+		expected [][]string
+	}{
+		"No IDs": {
+			ids:      nil,
+			expected: nil,
+		},
+		"Fewer IDs than the batch size": {
+			ids:      []string{"a", "b"},
+			expected: [][]string{{"a", "b"}},
+		},
+		"IDs split into batches": {
+			ids:      []string{"a", "b", "c", "d", "e"},
+			expected: [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, Batches(test.ids, 2))
+		})
+	}
+}
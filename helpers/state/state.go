@@ -0,0 +1,137 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Version of the state file format. Bump it whenever a field is removed or changes its meaning.
+const Version = 1
+
+// How long a resource has been seen unused, across the runs recorded in the state file
+type Entry struct {
+	FirstIdleAt time.Time `json:"first_idle_at"`
+	LastIdleAt  time.Time `json:"last_idle_at"`
+	IdleRuns    int       `json:"idle_runs"`
+}
+
+// Resources seen unused by previous runs, kept in a local file so they can be required to stay unused for a while
+// before being deleted. It's safe for concurrent use
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	Version   int               `json:"version"`
+	Resources map[string]*Entry `json:"resources"`
+
+	// When each resource was first listed, for the ones that don't tell when they were created
+	Seen map[string]time.Time `json:"seen,omitempty"`
+}
+
+// Read the state file passed as parameter. The store is empty when the file doesn't exist yet
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, Version: Version, Resources: map[string]*Entry{}, Seen: map[string]time.Time{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file '%s': %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("error decoding state file '%s': %w", path, err)
+	}
+	if s.Version != Version {
+		return nil, fmt.Errorf("state file '%s' has version %d, but only version %d is supported", path, s.Version, Version)
+	}
+	if s.Resources == nil {
+		s.Resources = map[string]*Entry{}
+	}
+	if s.Seen == nil {
+		s.Seen = map[string]time.Time{}
+	}
+	return s, nil
+}
+
+// Write the store back to the file it was read from
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding state: %w", err)
+	}
+	if err := os.WriteFile(s.path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("error writing state file '%s': %w", s.path, err)
+	}
+	return nil
+}
+
+// Record the decision a resource got in the run started at the time passed as parameter, returning how long it has
+// been unused. Resources in use are forgotten, while the ones that couldn't be evaluated are left as they were.
+// Observing the same resource again in the same run doesn't count as another run
+func (s *Store) Observe(key string, decision resource.Decision, run time.Time) Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.Resources[key]
+	switch {
+	case decision == resource.Keep:
+		delete(s.Resources, key)
+		return Entry{}
+	case decision != resource.Deletable && entry == nil:
+		return Entry{}
+	case decision != resource.Deletable:
+		return *entry
+	case entry == nil:
+		entry = &Entry{FirstIdleAt: run, LastIdleAt: run, IdleRuns: 1}
+		s.Resources[key] = entry
+	case !entry.LastIdleAt.Equal(run):
+		entry.LastIdleAt = run
+		entry.IdleRuns++
+	}
+	return *entry
+}
+
+// When the resource was first seen, recording the time passed as parameter when it's the first time
+func (s *Store) FirstSeen(key string, now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen, ok := s.Seen[key]
+	if !ok {
+		seen = now
+		s.Seen[key] = seen
+	}
+	return seen
+}
+
+// Forget the resources whose keys start with the prefix passed as parameter and that weren't listed anymore, since
+// they were deleted in the meantime
+func (s *Store) Prune(prefix string, listed []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := make(map[string]bool, len(listed))
+	for _, id := range listed {
+		found[id] = true
+	}
+	for key := range s.Resources {
+		if id, ok := strings.CutPrefix(key, prefix); ok && !found[id] {
+			delete(s.Resources, key)
+		}
+	}
+	for key := range s.Seen {
+		if id, ok := strings.CutPrefix(key, prefix); ok && !found[id] {
+			delete(s.Seen, key)
+		}
+	}
+}
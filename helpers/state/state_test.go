@@ -0,0 +1,71 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve(t *testing.T) {
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+	s := &Store{Resources: map[string]*Entry{}}
+
+	// Runs are only counted once, no matter how many times the resource is observed in them
+	assert.Equal(t, Entry{FirstIdleAt: first, LastIdleAt: first, IdleRuns: 1}, s.Observe("ebs/vol-1", resource.Deletable, first))
+	assert.Equal(t, Entry{FirstIdleAt: first, LastIdleAt: first, IdleRuns: 1}, s.Observe("ebs/vol-1", resource.Deletable, first))
+	assert.Equal(t, Entry{FirstIdleAt: first, LastIdleAt: second, IdleRuns: 2}, s.Observe("ebs/vol-1", resource.Deletable, second))
+
+	// Resources that couldn't be evaluated are left as they were, and the ones in use are forgotten
+	assert.Equal(t, Entry{FirstIdleAt: first, LastIdleAt: second, IdleRuns: 2}, s.Observe("ebs/vol-1", resource.Unknown, second.Add(time.Hour)))
+	assert.Equal(t, Entry{}, s.Observe("ebs/vol-1", resource.Keep, second.Add(time.Hour)))
+	assert.Empty(t, s.Resources)
+}
+
+func TestFirstSeen(t *testing.T) {
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := &Store{Seen: map[string]time.Time{}}
+
+	// Later runs keep the time the resource was first seen at
+	assert.Equal(t, first, s.FirstSeen("eni/eni-1", first))
+	assert.Equal(t, first, s.FirstSeen("eni/eni-1", first.Add(time.Hour)))
+}
+
+func TestPrune(t *testing.T) {
+	s := &Store{
+		Resources: map[string]*Entry{"ebs/vol-1": {}, "ebs/vol-2": {}, "eni/eni-1": {}},
+		Seen:      map[string]time.Time{"ebs/vol-2": {}, "eni/eni-1": {}},
+	}
+
+	s.Prune("ebs/", []string{"vol-1"})
+
+	assert.Equal(t, map[string]*Entry{"ebs/vol-1": {}, "eni/eni-1": {}}, s.Resources)
+	assert.Equal(t, map[string]time.Time{"eni/eni-1": {}}, s.Seen)
+}
+
+func TestLoadAndSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	run := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// A missing file is an empty store
+	s, err := Load(path)
+	require.NoError(t, err)
+	assert.Empty(t, s.Resources)
+
+	s.Observe("ebs/vol-1", resource.Deletable, run)
+	require.NoError(t, s.Save())
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*Entry{"ebs/vol-1": {FirstIdleAt: run, LastIdleAt: run, IdleRuns: 1}}, loaded.Resources)
+
+	// Files written by other versions are refused
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 2}`), 0o600))
+	_, err = Load(path)
+	assert.EqualError(t, err, "state file '"+path+"' has version 2, but only version 1 is supported")
+}
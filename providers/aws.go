@@ -4,12 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	elasticblockstorage "github.com/loureirovinicius/cleanup/aws/service/ec2/elasticBlockStorage"
 	elasticip "github.com/loureirovinicius/cleanup/aws/service/ec2/elasticIp"
 	elasticnetworkinterface "github.com/loureirovinicius/cleanup/aws/service/ec2/elasticNetworkInterface"
@@ -19,9 +25,12 @@ import (
 	"github.com/spf13/viper"
 )
 
+// Services supported by the AWS provider, in the order they're loaded when all of them are requested
+var awsServices = []string{"targetGroup", "loadBalancer", "eni", "eip", "ebs"}
+
 type AWS struct {
-	config  Config
-	Service Cleanable
+	config   Config
+	Services []Service
 }
 
 type Config struct {
@@ -31,8 +40,56 @@ type Config struct {
 	// AWS Configuration Profile to be used. DO NOT USE THIS FIELD FOR PRODUCTION PURPOSES.
 	Profile Profile
 
-	// AWS Region
-	Region string
+	// AWS Regions where the services are loaded. Contains only 'all' when every enabled region should be discovered.
+	Regions []string
+
+	// AWS Accounts where the services are loaded by assuming a role in each one of them. When empty, services are
+	// loaded only for the account the credentials above belong to.
+	Accounts []Account
+
+	// AWS Organization whose accounts are enumerated and added to the accounts above.
+	Organization Organization
+}
+
+type Account struct {
+	// AWS Account ID. Filled from the role ARN.
+	ID string
+
+	// ARN of the IAM Role assumed in the account.
+	RoleARN string `mapstructure:"role_arn"`
+
+	// External ID required by the role's trust policy, if any.
+	ExternalID string `mapstructure:"external_id"`
+
+	// Name of the session created when assuming the role. Defaults to 'cleanup'.
+	SessionName string `mapstructure:"session_name"`
+
+	// Whether the account was enumerated from the organization instead of being configured.
+	enumerated bool
+}
+
+// Session name used when assuming roles if none was set
+const defaultSessionName = "cleanup"
+
+// Returned along with the services that could be loaded when some accounts couldn't be accessed
+var ErrAccountsSkipped = errors.New("some AWS accounts were skipped")
+
+// Check whether the client can access its account, which forces the role to be assumed right away. It's a variable so
+// tests don't need to reach AWS
+var verifyAccess = func(ctx context.Context, client *aws.Config) error {
+	_, err := client.Credentials.Retrieve(ctx)
+	return err
+}
+
+// Region used to discover the enabled regions when none was set through the 'aws.region' config
+const defaultRegion = "us-east-1"
+
+// Keyword used to request every region enabled in the account
+const allRegions = "all"
+
+// Subset of the EC2 API used to discover the regions enabled in the account
+type RegionsAPI interface {
+	DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error)
 }
 
 type Profile struct {
@@ -52,35 +109,139 @@ type Credentials struct {
 }
 
 // Call all the functions below in order to properly set the required configs for the cloud provider
-func (p *AWS) Initialize(ctx context.Context, serviceName string) error {
+func (p *AWS) Initialize(ctx context.Context, serviceNames ...string) error {
 	logger.Log(ctx, "debug", "Loading AWS configurations...")
 	if err := p.loadConfig(); err != nil {
 		return err
 	}
 	logger.Log(ctx, "debug", "AWS configs were loaded successfully!")
 
-	logger.Log(ctx, "debug", "Creating AWS client...")
-	client, err := p.createClient(ctx)
-	if err != nil {
-		return err
+	accounts := p.config.Accounts
+	if p.config.Organization.RoleName != "" {
+		logger.Log(ctx, "debug", "Enumerating AWS Organization accounts...")
+		client, err := p.createClient(ctx, viper.GetString("aws.region"), Account{})
+		if err != nil {
+			return err
+		}
+
+		found, err := listOrganizationAccounts(ctx, organizations.NewFromConfig(*client), p.config.Organization)
+		if err != nil {
+			return err
+		}
+		logger.Log(ctx, "debug", fmt.Sprintf("AWS Organization accounts found: %d", len(found)))
+
+		// Accounts explicitly configured take precedence over the enumerated ones
+		for _, account := range found {
+			if !slices.ContainsFunc(accounts, func(a Account) bool { return a.ID == account.ID }) {
+				accounts = append(accounts, account)
+			}
+		}
 	}
-	logger.Log(ctx, "debug", "AWS client was created successfully!")
 
-	service, err := p.loadService(ctx, client, serviceName)
+	// Without target accounts, services are loaded for the account the configured credentials belong to
+	if len(accounts) == 0 {
+		accounts = []Account{{}}
+	}
+
+	return p.loadAccounts(ctx, accounts, serviceNames)
+}
+
+// Load the services of every account passed as parameter. Accounts that can't be accessed are reported and skipped, so
+// they don't prevent the other ones from being swept
+func (p *AWS) loadAccounts(ctx context.Context, accounts []Account, serviceNames []string) error {
+	var skipped []error
+	p.Services = nil
+	for _, account := range accounts {
+		accountCtx := logger.WithAttrs(ctx, "account", account.ID)
+
+		if account.RoleARN != "" {
+			if err := p.checkAccess(accountCtx, account); err != nil {
+				// Some accounts of an organization (like its management account) usually don't have the role at all
+				if account.enumerated && !p.config.Organization.RequireAccess {
+					logger.Log(accountCtx, "info", fmt.Sprintf("Skipping AWS Organization account %s where the role can't be assumed: %v", account.ID, err))
+					continue
+				}
+				logger.Log(accountCtx, "error", fmt.Sprintf("Skipping AWS account %s: %v", account.ID, err))
+				skipped = append(skipped, err)
+				continue
+			}
+		}
+
+		if err := p.loadAccount(accountCtx, account, serviceNames); err != nil {
+			return err
+		}
+	}
+
+	if len(skipped) > 0 {
+		return fmt.Errorf("%w: %w", ErrAccountsSkipped, errors.Join(skipped...))
+	}
+
+	return nil
+}
+
+// Make sure the role of the account passed as parameter can be assumed
+func (p *AWS) checkAccess(ctx context.Context, account Account) error {
+	client, err := p.createClient(ctx, viper.GetString("aws.region"), account)
 	if err != nil {
 		return err
 	}
-	p.Service = service
+
+	if err := verifyAccess(ctx, client); err != nil {
+		return fmt.Errorf("could not assume role '%s' in account %s: %w", account.RoleARN, account.ID, err)
+	}
+
+	return nil
+}
+
+// Load the services for every region of the account passed as parameter
+func (p *AWS) loadAccount(ctx context.Context, account Account, serviceNames []string) error {
+	regions := p.config.Regions
+	if slices.Contains(regions, allRegions) {
+		logger.Log(ctx, "debug", "Discovering enabled AWS regions...")
+		client, err := p.createClient(ctx, viper.GetString("aws.region"), account)
+		if err != nil {
+			return err
+		}
+
+		regions, err = discoverRegions(ctx, ec2.NewFromConfig(*client))
+		if err != nil {
+			return err
+		}
+		logger.Log(ctx, "debug", fmt.Sprintf("Enabled AWS regions: %v", regions))
+	}
+
+	// Every service of a region shares the same client
+	for _, region := range regions {
+		logger.Log(ctx, "debug", fmt.Sprintf("Creating AWS client for region: %s", region))
+		client, err := p.createClient(ctx, region, account)
+		if err != nil {
+			return err
+		}
+		logger.Log(ctx, "debug", "AWS client was created successfully!")
+
+		for _, serviceName := range serviceNames {
+			service, err := p.loadService(ctx, client, serviceName)
+			if err != nil {
+				return err
+			}
+			p.Services = append(p.Services, Service{Name: serviceName, Region: region, Account: account.ID, Cleanable: service})
+		}
+	}
 
 	return nil
 }
 
-// Create a client for performing API calls
-func (p *AWS) createClient(ctx context.Context) (*aws.Config, error) {
+// Create a client for performing API calls in the region passed as parameter. When the account has a role, API calls
+// are performed with the credentials obtained by assuming it
+func (p *AWS) createClient(ctx context.Context, region string, account Account) (*aws.Config, error) {
 	credentials := credentials.NewStaticCredentialsProvider(p.config.Credentials.AccessKey, p.config.Credentials.SecretKey, "")
 
+	if region == "" {
+		region = defaultRegion
+	}
+
 	config, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(p.config.Region),
+		config.WithRegion(region),
 		config.WithSharedConfigFiles([]string{p.config.Profile.Path}),
 		config.WithSharedConfigProfile(p.config.Profile.Name),
 		config.WithCredentialsProvider(credentials),
@@ -89,16 +250,90 @@ func (p *AWS) createClient(ctx context.Context) (*aws.Config, error) {
 		return nil, fmt.Errorf("error creating AWS client: %v", err)
 	}
 
+	if account.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(config), account.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = account.SessionName
+			if account.ExternalID != "" {
+				o.ExternalID = aws.String(account.ExternalID)
+			}
+		})
+		config.Credentials = aws.NewCredentialsCache(provider)
+	}
+
 	return &config, nil
 }
 
+// Discover every region enabled in the account
+func discoverRegions(ctx context.Context, api RegionsAPI) ([]string, error) {
+	output, err := api.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("error calling the AWS DescribeRegions API: %w", err)
+	}
+
+	var regions []string
+	for _, region := range output.Regions {
+		regions = append(regions, *region.RegionName)
+	}
+	slices.Sort(regions)
+
+	return regions, nil
+}
+
+// Split the regions set as a comma-separated list (like the AWS_REGIONS env variable) into single regions
+func splitRegions(entries []string) []string {
+	var regions []string
+	for _, entry := range entries {
+		for _, region := range strings.Split(entry, ",") {
+			if region = strings.TrimSpace(region); region != "" {
+				regions = append(regions, region)
+			}
+		}
+	}
+	return regions
+}
+
 // Read configs set by Viper
 func (p *AWS) loadConfig() error {
-	region := viper.GetString("aws.region")
-	if region == "" {
+	// 'aws.regions' takes precedence over the single 'aws.region'
+	regions := splitRegions(viper.GetStringSlice("aws.regions"))
+	if len(regions) == 0 && viper.GetString("aws.region") != "" {
+		regions = []string{viper.GetString("aws.region")}
+	}
+	if len(regions) == 0 {
 		return errors.New("AWS region can't be empty")
 	}
-	p.config.Region = region
+	if slices.Contains(regions, allRegions) && len(regions) > 1 {
+		return fmt.Errorf("AWS regions must either be '%s' or a list of regions, got: %v", allRegions, regions)
+	}
+	p.config.Regions = regions
+
+	// This doesn't need to raise an error if empty because it's not a required configuration.
+	var accounts []Account
+	if err := viper.UnmarshalKey("aws.accounts", &accounts); err != nil {
+		return fmt.Errorf("error reading AWS accounts: %w", err)
+	}
+	for i, account := range accounts {
+		roleARN, err := arn.Parse(account.RoleARN)
+		if err != nil {
+			return fmt.Errorf("AWS account role ARN '%s' is not valid: %w", account.RoleARN, err)
+		}
+
+		accounts[i].ID = roleARN.AccountID
+		if account.SessionName == "" {
+			accounts[i].SessionName = defaultSessionName
+		}
+	}
+	p.config.Accounts = accounts
+
+	// This doesn't need to raise an error if empty because it's not a required configuration.
+	var organization Organization
+	if err := viper.UnmarshalKey("aws.organization", &organization); err != nil {
+		return fmt.Errorf("error reading AWS organization: %w", err)
+	}
+	if organization.SessionName == "" {
+		organization.SessionName = defaultSessionName
+	}
+	p.config.Organization = organization
 
 	// This doesn't need to raise an error if empty because it's not a required configuration.
 	profile := viper.GetStringMapString("aws.authentication.profile")
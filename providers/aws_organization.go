@@ -0,0 +1,183 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/loureirovinicius/cleanup/helpers/logger"
+)
+
+type Organization struct {
+	// Name of the IAM Role assumed in every account of the organization. Accounts are only enumerated when it's set.
+	RoleName string `mapstructure:"role_name"`
+
+	// External ID required by the role's trust policy, if any.
+	ExternalID string `mapstructure:"external_id"`
+
+	// Name of the session created when assuming the role. Defaults to 'cleanup'.
+	SessionName string `mapstructure:"session_name"`
+
+	// IDs of the organizational units (or roots) whose accounts are swept, including the ones of nested units.
+	// Every account of the organization is swept when empty.
+	ParentIDs []string `mapstructure:"parent_ids"`
+
+	// Tags an account must have to be swept, in the 'key=value' form. Viper lowercases map keys, so tags can't be a map.
+	Tags []string
+
+	// Whether accounts where the role can't be assumed make the execution fail, like the configured ones do. They're
+	// logged and skipped otherwise.
+	RequireAccess bool `mapstructure:"require_access"`
+}
+
+// Subset of the Organizations API used to enumerate the accounts of an organization
+type OrganizationsAPI interface {
+	organizations.ListAccountsAPIClient
+	organizations.ListAccountsForParentAPIClient
+	organizations.ListOrganizationalUnitsForParentAPIClient
+	organizations.ListTagsForResourceAPIClient
+}
+
+// Enumerate the active accounts of the organization matching its filters, along with the role to be assumed in them
+func listOrganizationAccounts(ctx context.Context, api OrganizationsAPI, org Organization) ([]Account, error) {
+	var found []types.Account
+
+	if len(org.ParentIDs) == 0 {
+		accounts, err := listAccounts(ctx, api)
+		if err != nil {
+			return nil, err
+		}
+		found = accounts
+	}
+
+	for _, parentID := range org.ParentIDs {
+		accounts, err := listAccountsForParent(ctx, api, parentID)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, accounts...)
+	}
+
+	var accounts []Account
+	for _, account := range found {
+		id := *account.Id
+
+		if account.Status != types.AccountStatusActive {
+			logger.Log(ctx, "debug", fmt.Sprintf("Skipping AWS account %s with status: %s", id, account.Status))
+			continue
+		}
+
+		if len(org.Tags) > 0 {
+			tagged, err := hasTags(ctx, api, id, parseTags(org.Tags))
+			if err != nil {
+				return nil, err
+			}
+			if !tagged {
+				logger.Log(ctx, "debug", fmt.Sprintf("Skipping AWS account %s without the required tags", id))
+				continue
+			}
+		}
+
+		// Roles are in the same partition as the organization
+		partition := "aws"
+		if accountARN, err := arn.Parse(aws.ToString(account.Arn)); err == nil {
+			partition = accountARN.Partition
+		}
+
+		accounts = append(accounts, Account{
+			ID:          id,
+			RoleARN:     fmt.Sprintf("arn:%s:iam::%s:role/%s", partition, id, org.RoleName),
+			ExternalID:  org.ExternalID,
+			SessionName: org.SessionName,
+			enumerated:  true,
+		})
+	}
+
+	return accounts, nil
+}
+
+// List every account of the organization
+func listAccounts(ctx context.Context, api OrganizationsAPI) ([]types.Account, error) {
+	var accounts []types.Account
+
+	paginator := organizations.NewListAccountsPaginator(api, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error calling the AWS ListAccounts API: %w", err)
+		}
+		accounts = append(accounts, page.Accounts...)
+	}
+
+	return accounts, nil
+}
+
+// List every account of the parent passed as parameter, including the ones of its nested organizational units
+func listAccountsForParent(ctx context.Context, api OrganizationsAPI, parentID string) ([]types.Account, error) {
+	var accounts []types.Account
+
+	paginator := organizations.NewListAccountsForParentPaginator(api, &organizations.ListAccountsForParentInput{ParentId: &parentID})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error calling the AWS ListAccountsForParent API: %w", err)
+		}
+		accounts = append(accounts, page.Accounts...)
+	}
+
+	units := organizations.NewListOrganizationalUnitsForParentPaginator(api, &organizations.ListOrganizationalUnitsForParentInput{ParentId: &parentID})
+	for units.HasMorePages() {
+		page, err := units.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error calling the AWS ListOrganizationalUnitsForParent API: %w", err)
+		}
+
+		for _, unit := range page.OrganizationalUnits {
+			nested, err := listAccountsForParent(ctx, api, *unit.Id)
+			if err != nil {
+				return nil, err
+			}
+			accounts = append(accounts, nested...)
+		}
+	}
+
+	return accounts, nil
+}
+
+// Check whether the account has every tag passed as parameter
+func hasTags(ctx context.Context, api OrganizationsAPI, accountID string, required map[string]string) (bool, error) {
+	tags := map[string]string{}
+
+	paginator := organizations.NewListTagsForResourcePaginator(api, &organizations.ListTagsForResourceInput{ResourceId: &accountID})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return false, fmt.Errorf("error calling the AWS ListTagsForResource API: %w", err)
+		}
+		for _, tag := range page.Tags {
+			tags[*tag.Key] = *tag.Value
+		}
+	}
+
+	for key, value := range required {
+		if tags[key] != value {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Parse tags in the 'key=value' form into a map. Tags without a value are mapped to an empty string
+func parseTags(tags []string) map[string]string {
+	parsed := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, "=")
+		parsed[key] = value
+	}
+	return parsed
+}
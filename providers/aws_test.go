@@ -2,13 +2,20 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
 	"github.com/loureirovinicius/cleanup/helpers/logger"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestInitialize(t *testing.T) {
@@ -135,7 +142,7 @@ func TestCreateClient(t *testing.T) {
 
 	for name, test := range cases {
 		t.Run(name, func(t *testing.T) {
-			client, err := provider.createClient(ctx)
+			client, err := provider.createClient(ctx, "us-east-1", Account{})
 			test.testCase(t, client, err)
 		})
 	}
@@ -179,3 +186,368 @@ func TestLoadConfig(t *testing.T) {
 		})
 	}
 }
+
+// MockRegionsAPI is a mock of the EC2 API used to discover regions
+type MockRegionsAPI struct {
+	mock.Mock
+}
+
+func (m *MockRegionsAPI) DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*ec2.DescribeRegionsOutput), args.Error(1)
+}
+
+func TestLoadRegions(t *testing.T) {
+	cases := map[string]struct {
+		helpers  func()
+		testCase func(*testing.T, []string, error)
+	}{
+		"Single region": {
+			helpers: func() {
+				viper.Set("aws.region", "us-east-1")
+			},
+			testCase: func(t *testing.T, output []string, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"us-east-1"}, output)
+			},
+		},
+		"List of regions takes precedence over the single region": {
+			helpers: func() {
+				viper.Set("aws.region", "us-east-1")
+				viper.Set("aws.regions", []string{"eu-west-1", "sa-east-1"})
+			},
+			testCase: func(t *testing.T, output []string, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"eu-west-1", "sa-east-1"}, output)
+			},
+		},
+		"Comma-separated regions from the environment": {
+			helpers: func() {
+				_ = os.Setenv("AWS_REGIONS", "us-east-1, eu-west-1")
+				_ = viper.BindEnv("aws.regions", "AWS_REGIONS")
+			},
+			testCase: func(t *testing.T, output []string, err error) {
+				_ = os.Unsetenv("AWS_REGIONS")
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"us-east-1", "eu-west-1"}, output)
+			},
+		},
+		"All regions": {
+			helpers: func() {
+				viper.Set("aws.regions", "all")
+			},
+			testCase: func(t *testing.T, output []string, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"all"}, output)
+			},
+		},
+		"All regions mixed with other regions": {
+			helpers: func() {
+				viper.Set("aws.regions", []string{"all", "eu-west-1"})
+			},
+			testCase: func(t *testing.T, output []string, err error) {
+				if assert.Error(t, err) {
+					assert.Equal(t, "AWS regions must either be 'all' or a list of regions, got: [all eu-west-1]", err.Error())
+				}
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			provider := AWS{}
+
+			test.helpers()
+
+			err := provider.loadConfig()
+			test.testCase(t, provider.config.Regions, err)
+		})
+	}
+}
+
+func TestDiscoverRegions(t *testing.T) {
+	mockSvc := new(MockRegionsAPI)
+	mockSvc.On("DescribeRegions", mock.Anything, mock.Anything).Return(&ec2.DescribeRegionsOutput{
+		Regions: []types.Region{
+			{RegionName: aws.String("us-east-1")},
+			{RegionName: aws.String("eu-west-1")},
+		},
+	}, nil)
+
+	regions, err := discoverRegions(context.Background(), mockSvc)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"eu-west-1", "us-east-1"}, regions)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestInitializeMultipleRegions(t *testing.T) {
+	ctx := context.Background()
+	provider := AWS{}
+
+	viper.Set("aws.regions", []string{"us-east-1", "eu-west-1"})
+	defer viper.Reset()
+
+	err := provider.Initialize(ctx, "ebs", "eni")
+
+	assert.Nil(t, err)
+	var loaded []string
+	for _, service := range provider.Services {
+		loaded = append(loaded, service.String())
+	}
+	assert.Equal(t, []string{"ebs (us-east-1)", "eni (us-east-1)", "ebs (eu-west-1)", "eni (eu-west-1)"}, loaded)
+}
+
+func TestLoadAccounts(t *testing.T) {
+	cases := map[string]struct {
+		helpers  func()
+		testCase func(*testing.T, []Account, error)
+	}{
+		"No accounts": {
+			helpers: func() {},
+			testCase: func(t *testing.T, output []Account, err error) {
+				assert.Nil(t, err)
+				assert.Empty(t, output)
+			},
+		},
+		"Accounts are read from their role ARNs": {
+			helpers: func() {
+				viper.Set("aws.accounts", []map[string]string{
+					{"role_arn": "arn:aws:iam::111111111111:role/cleanup"},
+					{"role_arn": "arn:aws:iam::222222222222:role/cleanup", "external_id": "finops", "session_name": "sweep"},
+				})
+			},
+			testCase: func(t *testing.T, output []Account, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []Account{
+					{ID: "111111111111", RoleARN: "arn:aws:iam::111111111111:role/cleanup", SessionName: "cleanup"},
+					{ID: "222222222222", RoleARN: "arn:aws:iam::222222222222:role/cleanup", ExternalID: "finops", SessionName: "sweep"},
+				}, output)
+			},
+		},
+		"Invalid role ARN": {
+			helpers: func() {
+				viper.Set("aws.accounts", []map[string]string{{"role_arn": "cleanup"}})
+			},
+			testCase: func(t *testing.T, output []Account, err error) {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), "AWS account role ARN 'cleanup' is not valid")
+				}
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set("aws.region", "us-east-1")
+			provider := AWS{}
+
+			test.helpers()
+
+			err := provider.loadConfig()
+			test.testCase(t, provider.config.Accounts, err)
+		})
+	}
+}
+
+func TestCreateClientAssumingRole(t *testing.T) {
+	provider := AWS{}
+	account := Account{ID: "111111111111", RoleARN: "arn:aws:iam::111111111111:role/cleanup", SessionName: "cleanup"}
+
+	client, err := provider.createClient(context.Background(), "us-east-1", account)
+
+	assert.Nil(t, err)
+	if cache, ok := client.Credentials.(*aws.CredentialsCache); assert.True(t, ok, "expected credentials to be cached") {
+		assert.True(t, cache.IsCredentialsProvider(&stscreds.AssumeRoleProvider{}), "expected credentials to come from the assumed role")
+	}
+}
+
+func TestInitializeMultipleAccounts(t *testing.T) {
+	ctx := context.Background()
+	provider := AWS{}
+
+	viper.Set("aws.region", "us-east-1")
+	viper.Set("aws.accounts", []map[string]string{
+		{"role_arn": "arn:aws:iam::111111111111:role/cleanup"},
+		{"role_arn": "arn:aws:iam::222222222222:role/cleanup"},
+		{"role_arn": "arn:aws:iam::333333333333:role/cleanup"},
+	})
+	defer viper.Reset()
+
+	// The role can't be assumed in the second account
+	calls := 0
+	defer func(original func(context.Context, *aws.Config) error) { verifyAccess = original }(verifyAccess)
+	verifyAccess = func(ctx context.Context, client *aws.Config) error {
+		calls++
+		if calls == 2 {
+			return errors.New("access denied")
+		}
+		return nil
+	}
+
+	err := provider.Initialize(ctx, "ebs")
+
+	assert.ErrorIs(t, err, ErrAccountsSkipped)
+	assert.ErrorContains(t, err, "arn:aws:iam::222222222222:role/cleanup")
+	var loaded []string
+	for _, service := range provider.Services {
+		loaded = append(loaded, service.String())
+	}
+	assert.Equal(t, []string{"ebs (111111111111/us-east-1)", "ebs (333333333333/us-east-1)"}, loaded)
+}
+
+func TestLoadOrganizationAccounts(t *testing.T) {
+	viper.Set("aws.region", "us-east-1")
+	defer viper.Reset()
+
+	defer func(original func(context.Context, *aws.Config) error) { verifyAccess = original }(verifyAccess)
+
+	cases := map[string]struct {
+		requireAccess bool
+		expectedErr   bool
+	}{
+		"Inaccessible accounts are skipped":                          {},
+		"Inaccessible accounts are reported when access is required": {requireAccess: true, expectedErr: true},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			provider := AWS{}
+			provider.config.Regions = []string{"us-east-1"}
+			provider.config.Organization.RequireAccess = test.requireAccess
+			accounts := []Account{
+				{ID: "000000000000", RoleARN: "arn:aws:iam::000000000000:role/cleanup", SessionName: "cleanup", enumerated: true},
+				{ID: "111111111111", RoleARN: "arn:aws:iam::111111111111:role/cleanup", SessionName: "cleanup", enumerated: true},
+			}
+
+			// The role doesn't exist in the management account of the organization
+			calls := 0
+			verifyAccess = func(ctx context.Context, client *aws.Config) error {
+				calls++
+				if calls == 1 {
+					return errors.New("access denied")
+				}
+				return nil
+			}
+
+			err := provider.loadAccounts(context.Background(), accounts, []string{"ebs"})
+
+			if test.expectedErr {
+				assert.ErrorIs(t, err, ErrAccountsSkipped)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, provider.Services, 1)
+			assert.Equal(t, "111111111111", provider.Services[0].Account)
+		})
+	}
+}
+
+// MockOrganizationsAPI is a mock of the Organizations API used to enumerate accounts
+type MockOrganizationsAPI struct {
+	mock.Mock
+}
+
+func (m *MockOrganizationsAPI) ListAccounts(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*organizations.ListAccountsOutput), args.Error(1)
+}
+
+func (m *MockOrganizationsAPI) ListAccountsForParent(ctx context.Context, params *organizations.ListAccountsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsForParentOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*organizations.ListAccountsForParentOutput), args.Error(1)
+}
+
+func (m *MockOrganizationsAPI) ListOrganizationalUnitsForParent(ctx context.Context, params *organizations.ListOrganizationalUnitsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListOrganizationalUnitsForParentOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*organizations.ListOrganizationalUnitsForParentOutput), args.Error(1)
+}
+
+func (m *MockOrganizationsAPI) ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*organizations.ListTagsForResourceOutput), args.Error(1)
+}
+
+func orgAccount(id string, status orgtypes.AccountStatus) orgtypes.Account {
+	return orgtypes.Account{Id: aws.String(id), Arn: aws.String("arn:aws:organizations::000000000000:account/o-example/" + id), Status: status}
+}
+
+func TestListOrganizationAccounts(t *testing.T) {
+	cases := map[string]struct {
+		organization Organization
+		mock         func(*MockOrganizationsAPI)
+		expected     []string
+	}{
+		"Whole organization skipping suspended accounts": {
+			organization: Organization{RoleName: "cleanup"},
+			mock: func(m *MockOrganizationsAPI) {
+				m.On("ListAccounts", mock.Anything, &organizations.ListAccountsInput{}).Return(&organizations.ListAccountsOutput{
+					Accounts:  []orgtypes.Account{orgAccount("111111111111", orgtypes.AccountStatusActive)},
+					NextToken: aws.String("page-2"),
+				}, nil).Once()
+				m.On("ListAccounts", mock.Anything, &organizations.ListAccountsInput{NextToken: aws.String("page-2")}).Return(&organizations.ListAccountsOutput{
+					Accounts: []orgtypes.Account{
+						orgAccount("222222222222", orgtypes.AccountStatusSuspended),
+						orgAccount("333333333333", orgtypes.AccountStatusActive),
+					},
+				}, nil).Once()
+			},
+			expected: []string{"arn:aws:iam::111111111111:role/cleanup", "arn:aws:iam::333333333333:role/cleanup"},
+		},
+		"Organizational unit including nested ones": {
+			organization: Organization{RoleName: "cleanup", ParentIDs: []string{"ou-parent"}},
+			mock: func(m *MockOrganizationsAPI) {
+				m.On("ListAccountsForParent", mock.Anything, &organizations.ListAccountsForParentInput{ParentId: aws.String("ou-parent")}).Return(&organizations.ListAccountsForParentOutput{
+					Accounts: []orgtypes.Account{orgAccount("111111111111", orgtypes.AccountStatusActive)},
+				}, nil)
+				m.On("ListOrganizationalUnitsForParent", mock.Anything, &organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String("ou-parent")}).Return(&organizations.ListOrganizationalUnitsForParentOutput{
+					OrganizationalUnits: []orgtypes.OrganizationalUnit{{Id: aws.String("ou-child")}},
+				}, nil)
+				m.On("ListAccountsForParent", mock.Anything, &organizations.ListAccountsForParentInput{ParentId: aws.String("ou-child")}).Return(&organizations.ListAccountsForParentOutput{
+					Accounts: []orgtypes.Account{orgAccount("222222222222", orgtypes.AccountStatusActive)},
+				}, nil)
+				m.On("ListOrganizationalUnitsForParent", mock.Anything, &organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String("ou-child")}).Return(&organizations.ListOrganizationalUnitsForParentOutput{}, nil)
+			},
+			expected: []string{"arn:aws:iam::111111111111:role/cleanup", "arn:aws:iam::222222222222:role/cleanup"},
+		},
+		"Only accounts with the required tags": {
+			organization: Organization{RoleName: "cleanup", Tags: []string{"Environment=dev"}},
+			mock: func(m *MockOrganizationsAPI) {
+				m.On("ListAccounts", mock.Anything, mock.Anything).Return(&organizations.ListAccountsOutput{
+					Accounts: []orgtypes.Account{
+						orgAccount("111111111111", orgtypes.AccountStatusActive),
+						orgAccount("222222222222", orgtypes.AccountStatusActive),
+					},
+				}, nil)
+				m.On("ListTagsForResource", mock.Anything, &organizations.ListTagsForResourceInput{ResourceId: aws.String("111111111111")}).Return(&organizations.ListTagsForResourceOutput{
+					Tags: []orgtypes.Tag{{Key: aws.String("Environment"), Value: aws.String("prod")}},
+				}, nil)
+				m.On("ListTagsForResource", mock.Anything, &organizations.ListTagsForResourceInput{ResourceId: aws.String("222222222222")}).Return(&organizations.ListTagsForResourceOutput{
+					Tags: []orgtypes.Tag{{Key: aws.String("Environment"), Value: aws.String("dev")}},
+				}, nil)
+			},
+			expected: []string{"arn:aws:iam::222222222222:role/cleanup"},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockSvc := new(MockOrganizationsAPI)
+			test.mock(mockSvc)
+
+			accounts, err := listOrganizationAccounts(context.Background(), mockSvc, test.organization)
+
+			assert.NoError(t, err)
+			var roles []string
+			for _, account := range accounts {
+				roles = append(roles, account.RoleARN)
+			}
+			assert.Equal(t, test.expected, roles)
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
@@ -2,10 +2,120 @@ package providers
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
+	"github.com/loureirovinicius/cleanup/providers/resource"
 )
 
 type Cleanable interface {
+	List(context.Context) ([]resource.Resource, error)
+	Validate(context.Context, string) (resource.Verdict, error)
+	Delete(context.Context, string) error
+}
+
+// Services only listing the IDs of their resources and telling whether they're empty, as every service used to.
+// They're turned into a Cleanable by Legacy
+type LegacyCleanable interface {
 	List(context.Context) ([]string, error)
 	Validate(context.Context, string) (bool, error)
 	Delete(context.Context, string) error
 }
+
+// Adapt a service only listing IDs into a Cleanable, whose resources don't have any other metadata and whose verdicts
+// have a single reason
+func Legacy(service LegacyCleanable) Cleanable {
+	return legacyCleanable{service}
+}
+
+type legacyCleanable struct {
+	service LegacyCleanable
+}
+
+func (l legacyCleanable) List(ctx context.Context) ([]resource.Resource, error) {
+	ids, err := l.service.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return resource.FromIDs(ids), nil
+}
+
+func (l legacyCleanable) Validate(ctx context.Context, id string) (resource.Verdict, error) {
+	empty, err := l.service.Validate(ctx, id)
+	if err != nil {
+		return resource.Verdict{}, err
+	}
+
+	if empty {
+		return resource.NewVerdict(resource.Reason{Rule: "empty", Decision: resource.Deletable, Message: "resource is empty"}), nil
+	}
+	return resource.NewVerdict(resource.Reason{Rule: "empty", Decision: resource.Keep, Message: "resource is not empty"}), nil
+}
+
+func (l legacyCleanable) Delete(ctx context.Context, id string) error {
+	return l.service.Delete(ctx, id)
+}
+
+// Implemented by services that validate resources against the metadata captured by List. Refresh updates that metadata
+// for the resources passed as parameter with as few calls as possible, so they're validated against their current
+// state right before being deleted
+type Refresher interface {
+	Refresh(context.Context, []string) error
+}
+
+// Implemented by services whose resources can be tagged, like when marking unused resources before sweeping them or
+// leasing them until they expire. Tag sets a tag of a resource, replacing its value, and Untag removes it
+type Tagger interface {
+	Tag(ctx context.Context, id, key, value string) error
+	Untag(ctx context.Context, id, key string) error
+}
+
+// Implemented by services whose provider's API can narrow down the resources they list. Filter restricts the resources
+// returned by the next List calls to the ones whose fields equal the values passed as parameter, ignoring the fields
+// the API can't filter by. Fields are named like in filter expressions, like 'state' or 'tag:team'
+type Filterer interface {
+	Filter(map[string]string)
+}
+
+// Implemented by services whose checks only look at the metadata captured by List, so the rules of a policy can
+// replace them without missing anything they look at. CheckedAttributes returns the attributes of the resources the
+// checks look at, like 'state'
+type MetadataChecker interface {
+	CheckedAttributes() []string
+}
+
+// Cleanable loaded for a provider, identified by the service name it was requested with and where it lives
+type Service struct {
+	Name    string
+	Region  string
+	Account string
+	Cleanable
+}
+
+// Name of the service along with its account and region, telling apart the same service loaded for different ones
+func (s Service) String() string {
+	location := strings.Trim(s.Account+"/"+s.Region, "/")
+	if location == "" {
+		return s.Name
+	}
+	return fmt.Sprintf("%s (%s)", s.Name, location)
+}
+
+// List the resources of the service, filling in the account and region they live in when the service doesn't
+func (s Service) List(ctx context.Context) ([]resource.Resource, error) {
+	resources, err := s.Cleanable.List(ctx)
+	for i := range resources {
+		if resources[i].Region == "" {
+			resources[i].Region = s.Region
+		}
+		if resources[i].Account == "" {
+			resources[i].Account = s.Account
+		}
+	}
+	return resources, err
+}
+
+// Attributes identifying the service in logs
+func (s Service) LogAttrs() []any {
+	return []any{"service", s.Name, "account", s.Account, "region", s.Region}
+}
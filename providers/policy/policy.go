@@ -0,0 +1,279 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/helpers/duration"
+	"github.com/loureirovinicius/cleanup/providers/resource"
+)
+
+// Validation rules of every service, keyed by the service name (like ebs or loadBalancer)
+type Policy struct {
+	Services map[string]ServicePolicy `yaml:"services"`
+}
+
+// Validation rules of a single service
+type ServicePolicy struct {
+	// Whether the rules replace the checks of the service, instead of being evaluated along with them.
+	Replace bool `yaml:"replace"`
+
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule making a decision about the resources matching its condition
+type Rule struct {
+	// Name of the rule, shown as the rule of the reasons it gives.
+	Name string `yaml:"name"`
+
+	// Decision made about the resources matching the condition.
+	Decision resource.Decision `yaml:"decision"`
+
+	// Decision made about the other resources. Rules without it have no say about the resources they don't match.
+	Otherwise resource.Decision `yaml:"otherwise"`
+
+	// Explanation of the decision made about the resources matching the condition, if any.
+	Message string `yaml:"message"`
+
+	When Condition `yaml:"when"`
+}
+
+// Condition a resource matches. It either combines other conditions (all, any or not), checks an attribute or a tag
+// with every operator set, or checks how long ago the resource was created
+type Condition struct {
+	All []Condition `yaml:"all"`
+	Any []Condition `yaml:"any"`
+	Not *Condition  `yaml:"not"`
+
+	// Attribute (like state or vpc_id) or tag checked by the operators.
+	Attribute string `yaml:"attribute"`
+	Tag       string `yaml:"tag"`
+
+	// Operators checking the attribute or tag. Missing attributes and tags only match 'exists: false'.
+	Equals      *string  `yaml:"equals"`
+	In          []string `yaml:"in"`
+	Matches     string   `yaml:"matches"`
+	Exists      *bool    `yaml:"exists"`
+	GreaterThan *float64 `yaml:"greater_than"`
+	LessThan    *float64 `yaml:"less_than"`
+
+	// How long ago the resource was created (like 7d or 12h). Resources whose creation time is unknown never match.
+	OlderThan   string `yaml:"older_than"`
+	YoungerThan string `yaml:"younger_than"`
+
+	matches     *regexp.Regexp
+	olderThan   time.Duration
+	youngerThan time.Duration
+}
+
+// Check that every rule of the policy is well-formed, compiling their regular expressions and durations
+func (p *Policy) Check() error {
+	for name, service := range p.Services {
+		for i := range service.Rules {
+			if err := service.Rules[i].check(); err != nil {
+				return fmt.Errorf("invalid rule %d of service '%s': %w", i+1, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Rule) check() error {
+	if r.Name == "" {
+		return errors.New("rules must have a name")
+	}
+	if !validDecision(r.Decision) {
+		return fmt.Errorf("decision must be deletable, keep or unknown, got: '%s'", r.Decision)
+	}
+	if r.Otherwise != "" && !validDecision(r.Otherwise) {
+		return fmt.Errorf("otherwise must be deletable, keep or unknown, got: '%s'", r.Otherwise)
+	}
+	if err := r.When.compile(); err != nil {
+		return fmt.Errorf("rule '%s': %w", r.Name, err)
+	}
+	return nil
+}
+
+func validDecision(d resource.Decision) bool {
+	return d == resource.Deletable || d == resource.Keep || d == resource.Unknown
+}
+
+// Check that the condition is of a single kind, compiling its regular expressions and durations
+func (c *Condition) compile() error {
+	kinds := 0
+	for _, set := range []bool{c.All != nil || c.Any != nil || c.Not != nil, c.Attribute != "" || c.Tag != "", c.OlderThan != "" || c.YoungerThan != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 || (c.Attribute != "" && c.Tag != "") {
+		return errors.New("conditions must either combine other ones (all, any or not), check an attribute or a tag, or check the age")
+	}
+
+	for i := range c.All {
+		if err := c.All[i].compile(); err != nil {
+			return err
+		}
+	}
+	for i := range c.Any {
+		if err := c.Any[i].compile(); err != nil {
+			return err
+		}
+	}
+	if c.Not != nil {
+		return c.Not.compile()
+	}
+
+	if c.Attribute != "" || c.Tag != "" {
+		if c.Equals == nil && c.In == nil && c.Matches == "" && c.Exists == nil && c.GreaterThan == nil && c.LessThan == nil {
+			return fmt.Errorf("condition on '%s' must have an operator (equals, in, matches, exists, greater_than or less_than)", c.subject())
+		}
+		if c.Matches != "" {
+			re, err := regexp.Compile(c.Matches)
+			if err != nil {
+				return fmt.Errorf("invalid regular expression of '%s': %w", c.subject(), err)
+			}
+			c.matches = re
+		}
+		return nil
+	}
+
+	var err error
+	if c.OlderThan != "" {
+		if c.olderThan, err = duration.Parse(c.OlderThan); err != nil {
+			return err
+		}
+	}
+	if c.YoungerThan != "" {
+		if c.youngerThan, err = duration.Parse(c.YoungerThan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Attribute or tag checked by the condition, named like in filter expressions
+func (c *Condition) subject() string {
+	if c.Tag != "" {
+		return "tag:" + c.Tag
+	}
+	return c.Attribute
+}
+
+// Attributes looked at by the rules of the service
+func (p ServicePolicy) Attributes() []string {
+	var attributes []string
+	var walk func(c *Condition)
+	walk = func(c *Condition) {
+		if c.Attribute != "" && !slices.Contains(attributes, c.Attribute) {
+			attributes = append(attributes, c.Attribute)
+		}
+		for i := range c.All {
+			walk(&c.All[i])
+		}
+		for i := range c.Any {
+			walk(&c.Any[i])
+		}
+		if c.Not != nil {
+			walk(c.Not)
+		}
+	}
+	for i := range p.Rules {
+		walk(&p.Rules[i].When)
+	}
+	return attributes
+}
+
+// Reasons given by every rule of the service about the resource passed as parameter. Ages are computed against now
+func (p ServicePolicy) Evaluate(res resource.Resource, now time.Time) []resource.Reason {
+	var reasons []resource.Reason
+	for _, rule := range p.Rules {
+		evidence := map[string]string{}
+		matched := rule.When.match(res, now, evidence)
+		if len(evidence) == 0 {
+			evidence = nil
+		}
+
+		switch {
+		case matched:
+			message := rule.Message
+			if message == "" {
+				message = fmt.Sprintf("resource matches the %s rule", rule.Name)
+			}
+			reasons = append(reasons, resource.Reason{Rule: rule.Name, Decision: rule.Decision, Message: message, Evidence: evidence})
+		case rule.Otherwise != "":
+			message := fmt.Sprintf("resource doesn't match the %s rule", rule.Name)
+			reasons = append(reasons, resource.Reason{Rule: rule.Name, Decision: rule.Otherwise, Message: message, Evidence: evidence})
+		}
+	}
+	return reasons
+}
+
+// Whether the resource matches the condition, recording the values it looked at in the evidence
+func (c *Condition) match(res resource.Resource, now time.Time, evidence map[string]string) bool {
+	switch {
+	case c.All != nil || c.Any != nil || c.Not != nil:
+		// Every condition is evaluated, so the evidence has every value looked at
+		matched := true
+		for i := range c.All {
+			matched = c.All[i].match(res, now, evidence) && matched
+		}
+		if c.Any != nil {
+			matchedAny := false
+			for i := range c.Any {
+				matchedAny = c.Any[i].match(res, now, evidence) || matchedAny
+			}
+			matched = matched && matchedAny
+		}
+		if c.Not != nil {
+			matched = matched && !c.Not.match(res, now, evidence)
+		}
+		return matched
+	case c.OlderThan != "" || c.YoungerThan != "":
+		if res.CreatedAt.IsZero() {
+			evidence["age"] = "unknown"
+			return false
+		}
+		age := now.Sub(res.CreatedAt)
+		evidence["age"] = duration.Format(age)
+		return (c.OlderThan == "" || age > c.olderThan) && (c.YoungerThan == "" || age < c.youngerThan)
+	}
+
+	var value string
+	var ok bool
+	if c.Tag != "" {
+		value, ok = res.Tags[c.Tag]
+	} else {
+		value, ok = res.Attributes[c.Attribute]
+	}
+	if ok {
+		evidence[c.subject()] = value
+	}
+
+	if c.Exists != nil && *c.Exists != ok {
+		return false
+	}
+	if !ok {
+		return c.Exists != nil
+	}
+	if c.Equals != nil && value != *c.Equals {
+		return false
+	}
+	if c.In != nil && !slices.Contains(c.In, value) {
+		return false
+	}
+	if c.matches != nil && !c.matches.MatchString(value) {
+		return false
+	}
+	if c.GreaterThan != nil || c.LessThan != nil {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || (c.GreaterThan != nil && n <= *c.GreaterThan) || (c.LessThan != nil && n >= *c.LessThan) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loureirovinicius/cleanup/providers/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	volume := resource.Resource{
+		ID:         "vol-1234567890abcdef0",
+		Tags:       map[string]string{"team": "data"},
+		CreatedAt:  now.Add(-40 * 24 * time.Hour),
+		Attributes: map[string]string{"state": "available", "size": "500", "volume_type": "gp2"},
+	}
+
+	cases := map[string]struct {
+		rule     Rule
+		expected []resource.Reason
+	}{
+		"All conditions match": {
+			rule: Rule{Name: "available", Decision: resource.Deletable, Message: "volume isn't attached", When: Condition{All: []Condition{
+				{Attribute: "state", Equals: ptr("available")},
+				{Attribute: "size", GreaterThan: ptr(100.0), LessThan: ptr(1000.0)},
+				{OlderThan: "30d"},
+			}}},
+			expected: []resource.Reason{{Rule: "available", Decision: resource.Deletable, Message: "volume isn't attached", Evidence: map[string]string{"state": "available", "size": "500", "age": "40d"}}},
+		},
+		"Any condition matches": {
+			rule: Rule{Name: "owned", Decision: resource.Keep, When: Condition{Any: []Condition{
+				{Tag: "owner", Exists: ptr(true)},
+				{Tag: "team", In: []string{"data", "ml"}},
+			}}},
+			expected: []resource.Reason{{Rule: "owned", Decision: resource.Keep, Message: "resource matches the owned rule", Evidence: map[string]string{"tag:team": "data"}}},
+		},
+		"Negated condition": {
+			rule:     Rule{Name: "gp3", Decision: resource.Keep, When: Condition{Not: &Condition{Attribute: "volume_type", Matches: "^gp"}}},
+			expected: nil,
+		},
+		"Otherwise decision": {
+			rule:     Rule{Name: "young", Decision: resource.Keep, Otherwise: resource.Deletable, When: Condition{YoungerThan: "7d"}},
+			expected: []resource.Reason{{Rule: "young", Decision: resource.Deletable, Message: "resource doesn't match the young rule", Evidence: map[string]string{"age": "40d"}}},
+		},
+		"Missing attributes only match when they must not exist": {
+			rule:     Rule{Name: "untagged", Decision: resource.Deletable, When: Condition{All: []Condition{{Tag: "owner", Exists: ptr(false)}, {Attribute: "iops", Exists: ptr(false)}}}},
+			expected: []resource.Reason{{Rule: "untagged", Decision: resource.Deletable, Message: "resource matches the untagged rule"}},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := &Policy{Services: map[string]ServicePolicy{"ebs": {Rules: []Rule{c.rule}}}}
+			require.NoError(t, p.Check())
+			assert.Equal(t, c.expected, p.Services["ebs"].Evaluate(volume, now))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cases := map[string]struct {
+		rule Rule
+		err  string
+	}{
+		"Missing name": {
+			rule: Rule{Decision: resource.Keep, When: Condition{Tag: "owner", Exists: ptr(true)}},
+			err:  "invalid rule 1 of service 'ebs': rules must have a name",
+		},
+		"Invalid decision": {
+			rule: Rule{Name: "owned", Decision: "delete", When: Condition{Tag: "owner", Exists: ptr(true)}},
+			err:  "invalid rule 1 of service 'ebs': decision must be deletable, keep or unknown, got: 'delete'",
+		},
+		"Empty condition": {
+			rule: Rule{Name: "owned", Decision: resource.Keep},
+			err:  "invalid rule 1 of service 'ebs': rule 'owned': conditions must either combine other ones (all, any or not), check an attribute or a tag, or check the age",
+		},
+		"Condition of several kinds": {
+			rule: Rule{Name: "owned", Decision: resource.Keep, When: Condition{Tag: "owner", Exists: ptr(true), OlderThan: "7d"}},
+			err:  "invalid rule 1 of service 'ebs': rule 'owned': conditions must either combine other ones (all, any or not), check an attribute or a tag, or check the age",
+		},
+		"Missing operator": {
+			rule: Rule{Name: "state", Decision: resource.Deletable, When: Condition{All: []Condition{{Attribute: "state"}}}},
+			err:  "invalid rule 1 of service 'ebs': rule 'state': condition on 'state' must have an operator (equals, in, matches, exists, greater_than or less_than)",
+		},
+		"Invalid age": {
+			rule: Rule{Name: "old", Decision: resource.Deletable, When: Condition{OlderThan: "a month"}},
+			err:  "invalid rule 1 of service 'ebs': rule 'old': invalid duration 'a month'",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := &Policy{Services: map[string]ServicePolicy{"ebs": {Rules: []Rule{c.rule}}}}
+			assert.EqualError(t, p.Check(), c.err)
+		})
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	p := ServicePolicy{Rules: []Rule{
+		{Name: "available", When: Condition{All: []Condition{{Attribute: "state", Equals: ptr("available")}, {Not: &Condition{Attribute: "size", LessThan: ptr(10.0)}}}}},
+		{Name: "owned", When: Condition{Any: []Condition{{Tag: "owner", Exists: ptr(true)}, {Attribute: "state", In: []string{"error"}}}}},
+	}}
+
+	assert.Equal(t, []string{"state", "size"}, p.Attributes())
+}
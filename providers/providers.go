@@ -2,24 +2,56 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 )
 
-// Initialize the cloud provider being used during the execution
-func LoadProvider(ctx context.Context, provider string, service string) (Cleanable, error) {
+// Keyword used to request every service supported by a provider
+const AllServices = "all"
+
+// Initialize the cloud provider being used during the execution, loading every service requested.
+// Every service shares the same provider client. When some accounts couldn't be accessed, the services of the other
+// ones are returned along with an error wrapping ErrAccountsSkipped
+func LoadProvider(ctx context.Context, provider string, services ...string) ([]Service, error) {
 	switch provider {
 	case "aws":
 		// Instantiate AWS provider
 		aws := AWS{}
 
-		// Initialize the provider with required configs and specified service
-		if err := aws.Initialize(ctx, service); err != nil {
+		// Initialize the provider with required configs and specified services
+		err := aws.Initialize(ctx, expandServices(services, awsServices)...)
+		if errors.Is(err, ErrAccountsSkipped) {
+			return aws.Services, err
+		}
+		if err != nil {
 			return nil, fmt.Errorf("error initializing AWS functions. Reason: %v", err)
 		}
 
-		// Return only the requested service in the map
-		return aws.Service, nil
+		// Return only the requested services
+		return aws.Services, nil
 	default:
 		return nil, fmt.Errorf("provider %s is not supported", provider)
 	}
 }
+
+// Replace the 'all' keyword by every supported service and drop duplicates, keeping the order services were requested
+func expandServices(requested []string, supported []string) []string {
+	var services []string
+	for _, service := range requested {
+		if service == AllServices {
+			services = append(services, supported...)
+			continue
+		}
+		services = append(services, service)
+	}
+
+	var unique []string
+	for _, service := range services {
+		if !slices.Contains(unique, service) {
+			unique = append(unique, service)
+		}
+	}
+
+	return unique
+}
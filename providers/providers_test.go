@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/loureirovinicius/cleanup/helpers/logger"
+	"github.com/loureirovinicius/cleanup/providers/resource"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -53,3 +54,90 @@ func TestLoadProvider(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadMultipleServices(t *testing.T) {
+	// Mock dependencies
+	ctx := context.Background()
+
+	// Initialize logger
+	logger.InitializeLogger("info", "text", os.Stdout)
+	viper.Set("aws.region", "us-east-1")
+	defer viper.Reset()
+
+	cases := map[string]struct {
+		input    []string
+		testCase func(*testing.T, []Service, error)
+	}{
+		"Multiple services share the same provider": {
+			input: []string{"eni", "eip", "ebs"},
+			testCase: func(t *testing.T, output []Service, err error) {
+				assert.Nil(t, err, "error is not nil")
+				if assert.Len(t, output, 3) {
+					assert.Equal(t, "eni", output[0].Name)
+					assert.Equal(t, "eip", output[1].Name)
+					assert.Equal(t, "ebs", output[2].Name)
+				}
+			},
+		},
+		"All services are loaded once, even if requested again": {
+			input: []string{"ebs", "all"},
+			testCase: func(t *testing.T, output []Service, err error) {
+				assert.Nil(t, err, "error is not nil")
+				var names []string
+				for _, service := range output {
+					names = append(names, service.Name)
+				}
+				assert.Equal(t, []string{"ebs", "targetGroup", "loadBalancer", "eni", "eip"}, names)
+			},
+		},
+		"Unsupported service among supported ones": {
+			input: []string{"ebs", "eks"},
+			testCase: func(t *testing.T, output []Service, err error) {
+				assert.Nil(t, output)
+				if assert.Error(t, err) {
+					assert.Equal(t, "error initializing AWS functions. Reason: service eks is not supported", err.Error())
+				}
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			services, err := LoadProvider(ctx, "aws", test.input...)
+			test.testCase(t, services, err)
+		})
+	}
+}
+
+// Service still listing only the IDs of its resources
+type legacyService struct{}
+
+func (legacyService) List(ctx context.Context) ([]string, error) {
+	return []string{"res1", "res2"}, nil
+}
+
+func (legacyService) Validate(ctx context.Context, id string) (bool, error) {
+	return id == "res1", nil
+}
+
+func (legacyService) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+func TestLegacyService(t *testing.T) {
+	ctx := context.Background()
+	service := Service{Name: "legacy", Region: "us-east-1", Account: "111111111111", Cleanable: Legacy(legacyService{})}
+
+	resources, err := service.List(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Resource{
+		{ID: "res1", Region: "us-east-1", Account: "111111111111"},
+		{ID: "res2", Region: "us-east-1", Account: "111111111111"},
+	}, resources)
+
+	verdict, err := service.Validate(ctx, "res1")
+	assert.NoError(t, err)
+	assert.True(t, verdict.Deletable())
+	assert.Equal(t, "resource is empty", verdict.String())
+}
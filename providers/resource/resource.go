@@ -0,0 +1,106 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Tag marking a resource as unused, with the time it was found unused as its value
+const MarkTag = "cleanup-marked-at"
+
+// Tag with the time a resource expires at. It's kept until then and can be deleted afterwards, whether it's used or not
+const ExpiryTag = "cleanup-expires-at"
+
+// Resource found by a service, along with the metadata it was listed with so it doesn't need to be described again
+type Resource struct {
+	// ID used to validate and delete the resource. It's the ARN for services identifying resources by it.
+	ID string `json:"id"`
+
+	// ARN of the resource, if known.
+	ARN string `json:"arn,omitempty"`
+
+	// Name of the resource, usually taken from its 'Name' tag.
+	Name string `json:"name,omitempty"`
+
+	// Type of the resource, like 'AWS::EC2::Volume'.
+	Type string `json:"type,omitempty"`
+
+	// Region and account where the resource lives.
+	Region  string `json:"region,omitempty"`
+	Account string `json:"account,omitempty"`
+
+	// Tags of the resource, if the service lists them.
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// When the resource was created. It's zero when the service doesn't tell it, and left out of JSON documents then.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+
+	// Provider-specific attributes, like the state of a volume or the VPC of a network interface.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// ID of the resource along with its name, when it has one
+func (r Resource) String() string {
+	if r.Name == "" {
+		return r.ID
+	}
+	return fmt.Sprintf("%s (%s)", r.ID, r.Name)
+}
+
+// Encode the resource, leaving out its creation time when it's unknown, which omitempty doesn't do for times
+func (r Resource) MarshalJSON() ([]byte, error) {
+	type plain Resource
+	var createdAt *time.Time
+	if !r.CreatedAt.IsZero() {
+		createdAt = &r.CreatedAt
+	}
+	return json.Marshal(struct {
+		plain
+		CreatedAt *time.Time `json:"created_at,omitempty"`
+	}{plain(r), createdAt})
+}
+
+// When the resource was marked as unused by a previous run. Marks that can't be parsed are ignored
+func (r Resource) MarkedAt() (time.Time, bool) {
+	value, ok := r.Tags[MarkTag]
+	if !ok {
+		return time.Time{}, false
+	}
+	markedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return markedAt, true
+}
+
+// Parse the value of an expiry tag, which is either a time (like 2024-05-10T18:00:00Z) or a date (like 2024-05-10).
+// Dates expire once the whole day is over, in UTC
+func ParseExpiry(value string) (time.Time, error) {
+	if expiresAt, err := time.Parse(time.RFC3339, value); err == nil {
+		return expiresAt, nil
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expiry must be either a time like 2006-01-02T15:04:05Z or a date like 2006-01-02, got: %s", value)
+	}
+	return day.AddDate(0, 0, 1), nil
+}
+
+// Resources identified only by the IDs passed as parameter
+func FromIDs(ids []string) []Resource {
+	resources := make([]Resource, len(ids))
+	for i, id := range ids {
+		resources[i] = Resource{ID: id}
+	}
+	return resources
+}
+
+// IDs of the resources passed as parameter
+func IDs(resources []Resource) []string {
+	ids := make([]string, len(resources))
+	for i, resource := range resources {
+		ids[i] = resource.ID
+	}
+	return ids
+}
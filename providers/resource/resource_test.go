@@ -0,0 +1,64 @@
+package resource
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExpiry(t *testing.T) {
+	cases := map[string]struct {
+		value     string
+		expiresAt time.Time
+		err       bool
+	}{
+		"Time": {
+			value:     "2024-05-10T18:00:00Z",
+			expiresAt: time.Date(2024, 5, 10, 18, 0, 0, 0, time.UTC),
+		},
+		"Time with an offset": {
+			value:     "2024-05-10T18:00:00-03:00",
+			expiresAt: time.Date(2024, 5, 10, 21, 0, 0, 0, time.UTC),
+		},
+		"Date expiring once the day is over": {
+			value:     "2024-05-10",
+			expiresAt: time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC),
+		},
+		"Invalid value": {
+			value: "next friday",
+			err:   true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			expiresAt, err := ParseExpiry(c.value)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, c.expiresAt.Equal(expiresAt), "expected %v, got %v", c.expiresAt, expiresAt)
+		})
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	created := time.Date(2024, 5, 10, 18, 0, 0, 0, time.UTC)
+
+	// Unknown creation times are left out
+	data, err := json.Marshal(Resource{ID: "eni-1"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id": "eni-1"}`, string(data))
+
+	data, err = json.Marshal(Resource{ID: "vol-1", CreatedAt: created})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id": "vol-1", "created_at": "2024-05-10T18:00:00Z"}`, string(data))
+
+	var decoded Resource
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, Resource{ID: "vol-1", CreatedAt: created}, decoded)
+}
@@ -0,0 +1,75 @@
+package resource
+
+import "strings"
+
+// Whether a resource can be deleted
+type Decision string
+
+const (
+	Deletable Decision = "deletable"
+	Keep      Decision = "keep"
+	// The resource couldn't be evaluated, like when it's not found anymore. It's never deleted.
+	Unknown Decision = "unknown"
+)
+
+// Outcome of a single rule evaluated against a resource
+type Reason struct {
+	// Name of the rule, like 'state' or 'listeners'.
+	Rule string `json:"rule"`
+
+	Decision Decision `json:"decision"`
+
+	// Human-readable explanation of the decision.
+	Message string `json:"message"`
+
+	// Attribute values the rule looked at.
+	Evidence map[string]string `json:"evidence,omitempty"`
+}
+
+// Decision about a resource along with the reasons it was made
+type Verdict struct {
+	Decision Decision `json:"decision"`
+	Reasons  []Reason `json:"reasons"`
+}
+
+// Combine the outcome of every rule evaluated against a resource. It's only deletable when every rule says so, and a
+// single rule keeping it prevails over the ones that couldn't evaluate it
+func NewVerdict(reasons ...Reason) Verdict {
+	decisions := map[Decision]bool{}
+	for _, reason := range reasons {
+		decisions[reason.Decision] = true
+	}
+
+	verdict := Verdict{Decision: Unknown, Reasons: reasons}
+	switch {
+	case decisions[Keep]:
+		verdict.Decision = Keep
+	case decisions[Unknown]:
+		verdict.Decision = Unknown
+	case decisions[Deletable]:
+		verdict.Decision = Deletable
+	}
+
+	return verdict
+}
+
+// Whether the resource can be deleted
+func (v Verdict) Deletable() bool {
+	return v.Decision == Deletable
+}
+
+// Messages of the reasons backing the decision, leaving out the ones of rules that disagree with it
+func (v Verdict) Messages() []string {
+	var messages []string
+	for _, reason := range v.Reasons {
+		if reason.Decision == v.Decision {
+			messages = append(messages, reason.Message)
+		}
+	}
+	return messages
+}
+
+// Reasons backing the decision in a single line
+func (v Verdict) String() string {
+	return strings.Join(v.Messages(), "; ")
+}
@@ -0,0 +1,55 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVerdict(t *testing.T) {
+	deletable := Reason{Rule: "state", Decision: Deletable, Message: "volume is available"}
+	keep := Reason{Rule: "ignore-tag", Decision: Keep, Message: "volume has the cleanup-ignore=true tag"}
+	unknown := Reason{Rule: "found", Decision: Unknown, Message: "volume was not found"}
+
+	cases := map[string]struct {
+		reasons  []Reason
+		decision Decision
+		message  string
+	}{
+		"Every rule allows the deletion": {
+			reasons:  []Reason{deletable},
+			decision: Deletable,
+			message:  "volume is available",
+		},
+		"A single rule keeps the resource": {
+			reasons:  []Reason{deletable, keep},
+			decision: Keep,
+			message:  "volume has the cleanup-ignore=true tag",
+		},
+		"Keeping prevails over unknown": {
+			reasons:  []Reason{unknown, keep},
+			decision: Keep,
+			message:  "volume has the cleanup-ignore=true tag",
+		},
+		"A rule couldn't evaluate the resource": {
+			reasons:  []Reason{deletable, unknown},
+			decision: Unknown,
+			message:  "volume was not found",
+		},
+		"No rules": {
+			reasons:  nil,
+			decision: Unknown,
+			message:  "",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			verdict := NewVerdict(test.reasons...)
+
+			assert.Equal(t, test.decision, verdict.Decision)
+			assert.Equal(t, test.decision == Deletable, verdict.Deletable())
+			assert.Equal(t, test.message, verdict.String())
+		})
+	}
+}